@@ -1,35 +1,133 @@
 package service
 
 import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
 	"gifmaker-bot/internal/domain"
 )
 
 // LocaleService handles locale operations
 type LocaleService struct {
-	userLang *domain.UserLanguage
-	locales  map[string]*domain.Locale
+	userLang    *domain.UserLanguage
+	locales     map[string]*domain.Locale
+	defaultLang string
+	persister   Persister // nil keeps languages in memory only
+	saveMu      sync.Mutex
 }
 
-// NewLocaleService creates a new locale service
-func NewLocaleService(userLang *domain.UserLanguage) *LocaleService {
+// NewLocaleService creates a new locale service for the loaded locales.
+// Chats without a language get defaultLang, which must be one of them,
+// until they pick one. Template strings of the locales are rendered with
+// params, broken ones fall back to the default language.
+func NewLocaleService(userLang *domain.UserLanguage, locales map[string]*domain.Locale, defaultLang string, params LocaleParams, persister Persister) (*LocaleService, error) {
+	fallback, problems := renderLocale(locales[defaultLang], params, nil)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("default locale %q has broken templates: %s", defaultLang, strings.Join(problems, "; "))
+	}
+
+	rendered := map[string]*domain.Locale{defaultLang: fallback}
+	for lang, locale := range locales {
+		if lang == defaultLang {
+			continue
+		}
+		rendered[lang], problems = renderLocale(locale, params, fallback)
+		for _, problem := range problems {
+			log.Printf("Locale %s: %s, using %s", lang, problem, defaultLang)
+		}
+	}
+
 	return &LocaleService{
-		userLang: userLang,
-		locales:  domain.GetLocales(),
+		userLang:    userLang,
+		locales:     rendered,
+		defaultLang: defaultLang,
+		persister:   persister,
+	}, nil
+}
+
+// Load restores the languages saved before the last restart
+func (s *LocaleService) Load() error {
+	if s.persister == nil {
+		return nil
 	}
+
+	var langs map[int64]string
+	if err := s.persister.Load(&langs); err != nil {
+		return err
+	}
+	s.userLang.Load(langs)
+	return nil
 }
 
 // GetLocale returns the locale for a chat ID
 func (s *LocaleService) GetLocale(chatID int64) *domain.Locale {
-	lang := s.userLang.Get(chatID)
+	lang, ok := s.userLang.Get(chatID)
+	if !ok {
+		lang = s.defaultLang
+	}
 	locale, ok := s.locales[lang]
 	if !ok {
-		locale = s.locales["ru"] // fallback to Russian
+		locale = s.DefaultLocale()
 	}
 	return locale
 }
 
-// SetLanguage sets the language for a chat ID
+// DefaultLocale returns the locale of the default language
+func (s *LocaleService) DefaultLocale() *domain.Locale {
+	return s.locales[s.defaultLang]
+}
+
+// Languages returns the loaded locales sorted by language code
+func (s *LocaleService) Languages() []*domain.Locale {
+	languages := make([]*domain.Locale, 0, len(s.locales))
+	for _, locale := range s.locales {
+		languages = append(languages, locale)
+	}
+	slices.SortFunc(languages, func(a, b *domain.Locale) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+	return languages
+}
+
+// SetLanguage sets the language for a chat ID, ignoring unsupported ones
 func (s *LocaleService) SetLanguage(chatID int64, lang string) {
+	if _, ok := s.locales[lang]; !ok {
+		return
+	}
 	s.userLang.Set(chatID, lang)
+	s.save()
+}
+
+// DetectLanguage sets the language of a chat on first contact from the
+// language of the user's Telegram app, such as "en" or "pt-br". Unsupported
+// languages get the default one. Chats that already have a language keep it.
+func (s *LocaleService) DetectLanguage(chatID int64, languageCode string) {
+	if _, ok := s.userLang.Get(chatID); ok {
+		return
+	}
+
+	lang, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	if _, ok := s.locales[lang]; !ok {
+		lang = s.defaultLang
+	}
+	if s.userLang.SetIfMissing(chatID, lang) {
+		s.save()
+	}
+}
+
+// save writes all languages to the persister, logging failures
+func (s *LocaleService) save() {
+	if s.persister == nil {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := s.persister.Save(s.userLang.All()); err != nil {
+		log.Printf("Failed to save user languages: %v", err)
+	}
 }
 
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// LocaleParams are the config values locale strings can refer to as
+// template fields, such as {{.MaxDuration}}
+type LocaleParams struct {
+	MaxDuration   int // seconds of video converted as is
+	MaxTimelapse  int // seconds of video that can be sped up, 0 if timelapses are disabled
+	MaxSplitParts int // 0 if splitting is disabled
+	MaxSizeMB     int // largest GIF sent
+	DailyQuota    int // GIFs per chat per day, 0 means unlimited
+}
+
+// NewLocaleParams takes the locale parameters from the config
+func NewLocaleParams(cfg *domain.Config) LocaleParams {
+	return LocaleParams{
+		MaxDuration:   cfg.Processing.MaxVideoDuration,
+		MaxTimelapse:  cfg.Processing.MaxTimelapseDuration,
+		MaxSplitParts: cfg.Processing.MaxSplitParts,
+		MaxSizeMB:     domain.MaxOutputSize / (1024 * 1024),
+		DailyQuota:    cfg.Processing.DailyQuota,
+	}
+}
+
+// renderLocale returns a copy of a locale with its template strings
+// executed with params. Strings that fail to render are described in the
+// returned problems and taken from fallback, if it is set.
+func renderLocale(locale *domain.Locale, params LocaleParams, fallback *domain.Locale) (*domain.Locale, []string) {
+	rendered := *locale
+	target := reflect.ValueOf(&rendered).Elem()
+	funcs := template.FuncMap{"plural": pluralFunc(locale)}
+
+	var problems []string
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		if field.Type.Kind() != reflect.String || field.Tag.Get("locale") == "-" {
+			continue
+		}
+
+		text := target.Field(i).String()
+		if !strings.Contains(text, "{{") {
+			continue
+		}
+
+		str, err := renderString(field.Name, text, funcs, params)
+		if err != nil {
+			problems = append(problems, err.Error())
+			if fallback != nil {
+				str = reflect.ValueOf(fallback).Elem().Field(i).String()
+			}
+		}
+		target.Field(i).SetString(str)
+	}
+	return &rendered, problems
+}
+
+// renderString executes a single template string
+func renderString(name, text string, funcs template.FuncMap, params LocaleParams) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, params); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// pluralFunc returns the plural template function of a locale. It formats
+// a count with the plural forms of another key: {{plural "Seconds" .MaxDuration}}.
+func pluralFunc(locale *domain.Locale) func(string, int) (string, error) {
+	return func(key string, n int) (string, error) {
+		field := reflect.ValueOf(locale).Elem().FieldByName(key)
+		if !field.IsValid() || field.Type() != reflect.TypeOf(domain.PluralForms{}) {
+			return "", fmt.Errorf("%s is not a plural key", key)
+		}
+		return locale.Plural(field.Interface().(domain.PluralForms), n), nil
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"log"
+	"slices"
+	"sync"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// PresetService handles named settings presets of users and admins
+type PresetService struct {
+	store     *domain.PresetStore
+	admin     map[string]domain.UserSettings // presets from the config file
+	persister Persister                      // nil keeps presets in memory only
+	saveMu    sync.Mutex
+}
+
+// NewPresetService creates a new preset service.
+// Admin presets with names that can't be typed in a caption are skipped.
+func NewPresetService(store *domain.PresetStore, admin map[string]domain.UserSettings, persister Persister) *PresetService {
+	valid := make(map[string]domain.UserSettings, len(admin))
+	for name, preset := range admin {
+		if !domain.IsValidPresetName(name) {
+			log.Printf("Skipping preset %q from the config: names may only contain a-z, 0-9, _ and -", name)
+			continue
+		}
+		valid[name] = preset
+	}
+
+	return &PresetService{
+		store:     store,
+		admin:     valid,
+		persister: persister,
+	}
+}
+
+// Load restores the presets saved before the last restart
+func (s *PresetService) Load() error {
+	if s.persister == nil {
+		return nil
+	}
+
+	var presets map[int64]map[string]domain.UserSettings
+	if err := s.persister.Load(&presets); err != nil {
+		return err
+	}
+	s.store.Load(presets)
+	return nil
+}
+
+// Find returns a preset by name. Presets of the user win over admin
+// presets of the same name.
+func (s *PresetService) Find(chatID int64, name string) (domain.UserSettings, bool) {
+	if preset, ok := s.store.Get(chatID, name); ok {
+		return preset, true
+	}
+	preset, ok := s.admin[name]
+	return preset, ok
+}
+
+// UserPresets returns the sorted names of the presets saved by a user
+func (s *PresetService) UserPresets(chatID int64) []string {
+	return s.store.Names(chatID)
+}
+
+// AdminPresets returns the sorted names of the admin presets
+func (s *PresetService) AdminPresets() []string {
+	names := make([]string, 0, len(s.admin))
+	for name := range s.admin {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Save stores a preset of a user. It returns false if the user already
+// has the maximum number of presets.
+func (s *PresetService) Save(chatID int64, name string, preset domain.UserSettings) bool {
+	if !s.store.Put(chatID, name, preset) {
+		return false
+	}
+	s.save()
+	return true
+}
+
+// Delete removes a preset of a user and reports whether it existed
+func (s *PresetService) Delete(chatID int64, name string) bool {
+	if !s.store.Delete(chatID, name) {
+		return false
+	}
+	s.save()
+	return true
+}
+
+// save writes all user presets to the persister, logging failures
+func (s *PresetService) save() {
+	if s.persister == nil {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := s.persister.Save(s.store.All()); err != nil {
+		log.Printf("Failed to save presets: %v", err)
+	}
+}
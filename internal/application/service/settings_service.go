@@ -0,0 +1,78 @@
+package service
+
+import (
+	"log"
+	"sync"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// Persister loads and saves a value between restarts
+type Persister interface {
+	Load(v any) error
+	Save(v any) error
+}
+
+// SettingsService handles user conversion settings
+type SettingsService struct {
+	store     *domain.UserSettingsStore
+	persister Persister // nil keeps settings in memory only
+	saveMu    sync.Mutex
+}
+
+// NewSettingsService creates a new settings service
+func NewSettingsService(store *domain.UserSettingsStore, persister Persister) *SettingsService {
+	return &SettingsService{
+		store:     store,
+		persister: persister,
+	}
+}
+
+// Load restores the settings saved before the last restart
+func (s *SettingsService) Load() error {
+	if s.persister == nil {
+		return nil
+	}
+
+	var settings map[int64]domain.UserSettings
+	if err := s.persister.Load(&settings); err != nil {
+		return err
+	}
+	s.store.Load(settings)
+	return nil
+}
+
+// Get returns the settings for a chat ID
+func (s *SettingsService) Get(chatID int64) domain.UserSettings {
+	return s.store.Get(chatID)
+}
+
+// SetText sets the meme captions for a chat ID
+func (s *SettingsService) SetText(chatID int64, top, bottom string) {
+	s.Update(chatID, func(settings *domain.UserSettings) {
+		settings.TopText = top
+		settings.BottomText = bottom
+	})
+}
+
+// Update modifies the settings for a chat ID and saves them
+func (s *SettingsService) Update(chatID int64, update func(settings *domain.UserSettings)) {
+	s.store.Update(chatID, update)
+	s.save()
+}
+
+// save writes all settings to the persister. A failed save is logged and
+// the settings stay in memory until the next successful one.
+func (s *SettingsService) save() {
+	if s.persister == nil {
+		return
+	}
+
+	// Snapshot and write under one lock so an older snapshot never
+	// overwrites a newer one
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := s.persister.Save(s.store.All()); err != nil {
+		log.Printf("Failed to save user settings: %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package usecase
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"gifmaker-bot/internal/application/service"
@@ -76,13 +75,7 @@ func (qm *QueueManager) StartQueueUpdater() {
 			locale := qm.localeSvc.GetLocale(task.ChatID)
 			position := task.QueuePosition
 
-			var text string
-			if position == 1 {
-				text = fmt.Sprintf(locale.InQueue, position)
-			} else {
-				text = fmt.Sprintf(locale.InQueuePlural, position)
-			}
-
+			text := locale.Plural(locale.InQueue, position)
 			_ = qm.bot.EditMessageText(task.ChatID, task.StatusMsgID, text)
 		}
 	}
@@ -3,22 +3,43 @@ package usecase
 import (
 	"context"
 	"fmt"
+	"log"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"gifmaker-bot/internal/application/service"
 	"gifmaker-bot/internal/domain"
 	"gifmaker-bot/internal/infrastructure/ffmpeg"
+	"gifmaker-bot/internal/infrastructure/gifutil"
 	"gifmaker-bot/internal/infrastructure/storage"
 	"gifmaker-bot/internal/infrastructure/telegram"
 )
 
+// defaultSlideshowFrameDuration is used when the config doesn't set one
+const defaultSlideshowFrameDuration = 1.5
+
+// durationTolerance absorbs rounding in trimmed durations, in seconds
+const durationTolerance = 0.01
+
+// minTrimLength is the shortest part of a video in seconds a trim range
+// from the caption may leave
+const minTrimLength = 0.1
+
+// highlightCount is how many highlights are picked, the best part and
+// the runner-ups offered as buttons
+const highlightCount = 3
+
 // VideoProcessor handles video processing use cases
 type VideoProcessor struct {
-	bot       *telegram.Bot
-	converter *ffmpeg.Converter
-	fileStore *storage.FileStorage
-	config    *domain.Config
-	localeSvc *service.LocaleService
+	bot         *telegram.Bot
+	converter   *ffmpeg.Converter
+	fileStore   *storage.FileStorage
+	config      *domain.Config
+	localeSvc   *service.LocaleService
+	settingsSvc *service.SettingsService
+	sources     *domain.VideoSourceStore
+	quota       *domain.UsageQuota
 }
 
 // NewVideoProcessor creates a new video processor
@@ -28,13 +49,19 @@ func NewVideoProcessor(
 	fileStore *storage.FileStorage,
 	config *domain.Config,
 	localeSvc *service.LocaleService,
+	settingsSvc *service.SettingsService,
+	sources *domain.VideoSourceStore,
+	quota *domain.UsageQuota,
 ) *VideoProcessor {
 	return &VideoProcessor{
-		bot:       bot,
-		converter: converter,
-		fileStore: fileStore,
-		config:    config,
-		localeSvc: localeSvc,
+		bot:         bot,
+		converter:   converter,
+		fileStore:   fileStore,
+		config:      config,
+		localeSvc:   localeSvc,
+		settingsSvc: settingsSvc,
+		sources:     sources,
+		quota:       quota,
 	}
 }
 
@@ -51,18 +78,21 @@ func (vp *VideoProcessor) ProcessVideo(ctx context.Context, task *domain.Process
 	defer vp.fileStore.RemoveDir(tempDir)
 
 	videoPath := filepath.Join(tempDir, "video.mp4")
-	gifPath := filepath.Join(tempDir, "output.gif")
+	outputPath := filepath.Join(tempDir, "output."+task.Format.Extension())
 
-	// Download video
-	fileURL, err := vp.bot.GetFileLink(task.VideoFileID)
-	if err != nil {
-		vp.sendError(task.ChatID, locale.ErrorGetFile, locale)
-		return fmt.Errorf("failed to get file link: %w", err)
-	}
+	// Speed and boomerang change how long the result plays
+	config := vp.taskConfig(task)
+	maxDuration := float64(vp.config.Processing.MaxVideoDuration)
 
-	if err := vp.fileStore.DownloadFile(fileURL, videoPath); err != nil {
-		vp.sendError(task.ChatID, locale.ErrorDownload, locale)
-		return fmt.Errorf("failed to download video: %w", err)
+	if len(task.PhotoFileIDs) > 0 {
+		// Render album photos into a video that fits the duration limit
+		// and process it as usual
+		maxLength := maxDuration / config.GIF.OutputDuration(1)
+		if err := vp.prepareSlideshow(task, tempDir, videoPath, maxLength, locale); err != nil {
+			return err
+		}
+	} else if err := vp.downloadFile(task.ChatID, task.VideoFileID, videoPath, locale); err != nil {
+		return err
 	}
 
 	// Check video duration
@@ -72,10 +102,54 @@ func (vp *VideoProcessor) ProcessVideo(ctx context.Context, task *domain.Process
 		return fmt.Errorf("failed to get duration: %w", err)
 	}
 
-	if duration > float64(vp.config.Processing.MaxVideoDuration) {
-		errorMsg := fmt.Sprintf(locale.VideoTooLong, vp.config.Processing.MaxVideoDuration)
-		vp.sendError(task.ChatID, errorMsg, locale)
-		return fmt.Errorf("video too long: %.2f seconds", duration)
+	// Only the trimmed part is converted
+	videoDuration := duration
+	trimStart, trimEnd := task.Options.Trim.Bounds(duration)
+	duration = trimEnd - trimStart
+	if task.Options.Trim.IsSet() && duration < minTrimLength {
+		vp.sendError(task.ChatID, fmt.Sprintf(locale.TrimOutOfRange, formatClock(videoDuration)), locale)
+		return fmt.Errorf("trim range %.2f-%.2f is outside of %.2f seconds", task.Options.Trim.Start, task.Options.Trim.End, videoDuration)
+	}
+
+	// Sent videos that are too long are cut to their best part, the other
+	// ways to fit them are offered under the result
+	var fitButtons []telegram.InlineButton
+	var fitOffers []string
+	if vp.canReconvert(task) && !task.Options.Split && !task.Options.Timelapse && !task.Options.Highlight &&
+		config.GIF.OutputDuration(duration) > maxDuration+durationTolerance {
+		task.Options.Highlight = true
+		fitButtons, fitOffers = vp.fitOffers(config, duration, locale)
+	}
+
+	var highlights []domain.TimeRange
+	switch {
+	case task.Options.Split:
+		// Every part is checked against the limit separately
+		if parts := config.GIF.SplitParts(duration, maxDuration); parts > vp.config.Processing.MaxSplitParts {
+			vp.sendError(task.ChatID, locale.Plural(locale.VideoTooLong, vp.config.Processing.MaxVideoDuration), locale)
+			return fmt.Errorf("video too long to split: %d parts", parts)
+		}
+	case task.Options.Timelapse:
+		if duration > float64(vp.config.Processing.MaxTimelapseDuration) {
+			// Without timelapses only the usual limit applies
+			limit := max(vp.config.Processing.MaxTimelapseDuration, vp.config.Processing.MaxVideoDuration)
+			vp.sendError(task.ChatID, locale.Plural(locale.VideoTooLong, limit), locale)
+			return fmt.Errorf("video too long for timelapse: %.2f seconds", duration)
+		}
+		// setpts speeds the video up and the fps filter drops the extra frames
+		config.GIF.FitDuration(duration, maxDuration)
+	case task.Options.Highlight:
+		highlights = vp.findHighlights(videoPath, config, trimStart, trimEnd)
+		config.GIF.TrimStart, config.GIF.TrimEnd = highlights[0].Start, highlights[0].End
+	case len(task.PhotoFileIDs) > 0:
+		// Slideshows are rendered to fit, this only absorbs the rounding
+		// of photo durations to whole frames
+		config.GIF.FitDuration(duration, maxDuration)
+	default:
+		if outputDuration := config.GIF.OutputDuration(duration); outputDuration > maxDuration+durationTolerance {
+			vp.sendError(task.ChatID, locale.Plural(locale.VideoTooLong, vp.config.Processing.MaxVideoDuration), locale)
+			return fmt.Errorf("video too long: %.2f seconds", outputDuration)
+		}
 	}
 
 	// Update status: processing
@@ -83,34 +157,334 @@ func (vp *VideoProcessor) ProcessVideo(ctx context.Context, task *domain.Process
 		// Log error but continue
 	}
 
-	// Convert to GIF
-	if err := vp.converter.ConvertToGIF(videoPath, gifPath, vp.config); err != nil {
+	if task.Format == domain.FormatVideoNote {
+		return vp.processVideoNote(task, videoPath, filepath.Join(tempDir, "videonote.mp4"), locale)
+	}
+
+	if config.GIF.PerfectLoop && !task.Options.Split {
+		vp.applyPerfectLoop(videoPath, &config.GIF, videoDuration)
+	}
+
+	if task.Options.Split {
+		return vp.processSeries(task, videoPath, tempDir, config, duration, locale)
+	}
+
+	if !vp.reserveQuota(task.ChatID, 1, locale) {
+		return fmt.Errorf("daily quota exceeded")
+	}
+	sent := false
+	defer func() {
+		if !sent {
+			vp.quota.Release(task.ChatID, 1)
+		}
+	}()
+
+	if err := vp.convertOutput(task, videoPath, outputPath, config, locale); err != nil {
+		return err
+	}
+
+	// Send GIF
+	if err := vp.bot.EditMessageText(task.ChatID, task.StatusMsgID, locale.SendingGIF); err != nil {
+		// Log error but continue
+	}
+
+	// Highlights are captioned with their time, runner-ups become buttons
+	caption := locale.GIFReady
+	var keyboard [][]telegram.InlineButton
+	if len(highlights) > 0 {
+		caption = fmt.Sprintf(locale.HighlightCaption, formatClock(highlights[0].Start), formatClock(highlights[0].End))
+		var runnerUps []telegram.InlineButton
+		for i, highlight := range highlights[1:] {
+			runnerUps = append(runnerUps, telegram.InlineButton{
+				Text: fmt.Sprintf(locale.ButtonRunnerUp, formatClock(highlight.Start), formatClock(highlight.End)),
+				Data: domain.CallbackHighlightPrefix + strconv.Itoa(i+1),
+			})
+		}
+		if len(runnerUps) > 0 {
+			keyboard = append(keyboard, runnerUps)
+		}
+	}
+	if len(fitOffers) > 0 {
+		caption += "\n\n" + strings.Join(fitOffers, "\n")
+		keyboard = append(keyboard, fitButtons)
+	}
+	keyboard = append(keyboard, rerenderKeyboard(task.Format, locale)...)
+
+	msgID, err := vp.sendOutput(task, outputPath, caption, keyboard)
+	if err != nil {
+		vp.sendError(task.ChatID, locale.ErrorSendGIF, locale)
+		return fmt.Errorf("failed to send GIF: %w", err)
+	}
+	sent = true
+
+	// Buttons under the result convert the same video again
+	source := vp.videoSource(task, duration, highlights)
+	source.FPS, source.Width, source.Quality = config.GIF.FPS, config.GIF.Width, config.GIF.Quality
+	source.Speed = config.GIF.PlaybackSpeed()
+	vp.sources.Put(task.ChatID, msgID, source)
+
+	// Delete status message
+	_ = vp.bot.DeleteMessage(task.ChatID, task.StatusMsgID)
+
+	return nil
+}
+
+// processSeries splits a long video into consecutive parts that fit the
+// duration limit and sends them as a numbered series of GIFs
+func (vp *VideoProcessor) processSeries(
+	task *domain.ProcessingTask,
+	videoPath, tempDir string,
+	config *domain.Config,
+	duration float64,
+	locale *domain.Locale,
+) error {
+	parts := config.GIF.SplitParts(duration, float64(vp.config.Processing.MaxVideoDuration))
+	if !vp.reserveQuota(task.ChatID, parts, locale) {
+		return fmt.Errorf("daily quota exceeded for %d parts", parts)
+	}
+	sent := 0
+	defer func() {
+		vp.quota.Release(task.ChatID, parts-sent)
+	}()
+
+	// Equal parts look better than full ones followed by a short tail
+	partDuration := duration / float64(parts)
+	for i := 0; i < parts; i++ {
+		_ = vp.bot.EditMessageText(task.ChatID, task.StatusMsgID, fmt.Sprintf(locale.ProcessingPart, i+1, parts))
+
+		// Parts are cut from the trimmed part of the video, a reversed
+		// video starts from its end
+		segment := i
+		if config.GIF.Reverse {
+			segment = parts - 1 - i
+		}
+		partConfig := *config
+		partConfig.GIF.TrimStart = config.GIF.TrimStart + float64(segment)*partDuration
+		if segment < parts-1 {
+			partConfig.GIF.TrimEnd = config.GIF.TrimStart + float64(segment+1)*partDuration
+		}
+
+		partPath := filepath.Join(tempDir, fmt.Sprintf("part_%02d.%s", i+1, task.Format.Extension()))
+		if err := vp.convertOutput(task, videoPath, partPath, &partConfig, locale); err != nil {
+			return err
+		}
+
+		if _, err := vp.sendOutput(task, partPath, fmt.Sprintf(locale.PartCaption, i+1, parts), nil); err != nil {
+			vp.sendError(task.ChatID, locale.ErrorSendGIF, locale)
+			return fmt.Errorf("failed to send part %d/%d: %w", i+1, parts, err)
+		}
+		sent++
+	}
+
+	// Delete status message
+	_ = vp.bot.DeleteMessage(task.ChatID, task.StatusMsgID)
+
+	return nil
+}
+
+// findHighlights returns the most active windows between start and end
+// that play as long as the duration limit allows, best first. If scoring
+// fails the beginning of the range is used.
+func (vp *VideoProcessor) findHighlights(videoPath string, config *domain.Config, start, end float64) []domain.TimeRange {
+	duration := end - start
+	windowLength := duration * float64(vp.config.Processing.MaxVideoDuration) / config.GIF.OutputDuration(duration)
+
+	highlights, err := vp.converter.FindHighlights(videoPath, start, end, windowLength, highlightCount)
+	if err != nil {
+		log.Printf("Failed to find highlights: %v", err)
+		return []domain.TimeRange{{Start: start, End: min(start+windowLength, end)}}
+	}
+	return highlights
+}
+
+// applyPerfectLoop trims the clip to the frames that loop seamlessly or,
+// if there are none, makes the converter crossfade its end into its start
+func (vp *VideoProcessor) applyPerfectLoop(videoPath string, gif *domain.GIFConfig, videoDuration float64) {
+	start, end := domain.TimeRange{Start: gif.TrimStart, End: gif.TrimEnd}.Bounds(videoDuration)
+
+	loop, err := vp.converter.FindLoop(videoPath, start, end)
+	if err != nil {
+		log.Printf("Failed to find a loop: %v", err)
+	}
+	if loop != nil {
+		gif.TrimStart, gif.TrimEnd = loop.Start, loop.End
+		return
+	}
+	gif.LoopCrossfade = true
+}
+
+// convertOutput converts a video to the animation format of the task and
+// checks that the result fits the Telegram size limit
+func (vp *VideoProcessor) convertOutput(
+	task *domain.ProcessingTask,
+	videoPath, outputPath string,
+	config *domain.Config,
+	locale *domain.Locale,
+) error {
+	var err error
+	switch task.Format {
+	case domain.FormatSticker:
+		err = vp.converter.ConvertToSticker(videoPath, outputPath, config)
+	case domain.FormatMP4:
+		err = vp.converter.ConvertToMP4(videoPath, outputPath, config)
+	case domain.FormatWebP:
+		err = vp.converter.ConvertToWebP(videoPath, outputPath, config)
+	default:
+		err = vp.converter.ConvertToGIF(videoPath, outputPath, config)
+	}
+	if err != nil {
 		vp.sendError(task.ChatID, locale.ErrorConversion, locale)
 		return fmt.Errorf("failed to convert: %w", err)
 	}
 
+	// Shrink the GIF before checking it against the size limit.
+	// Optimization is best effort, the GIF is sent as is if it fails.
+	if config.GIF.Optimize && task.Format == domain.FormatGIF {
+		result, err := gifutil.Optimize(outputPath, config.GIF.Lossy)
+		if err != nil {
+			log.Printf("Failed to optimize GIF for chat %d: %v", task.ChatID, err)
+		} else {
+			log.Printf("Optimized GIF for chat %d: %d -> %d bytes, saved %d, dropped %d frames",
+				task.ChatID, result.OriginalSize, result.OptimizedSize, result.Saved(), result.DroppedFrames)
+		}
+	}
+
 	// Check if file exists and get size
-	fileSize, err := vp.fileStore.GetFileSize(gifPath)
+	fileSize, err := vp.fileStore.GetFileSize(outputPath)
 	if err != nil {
 		vp.sendError(task.ChatID, locale.ErrorCreateGIF, locale)
 		return fmt.Errorf("failed to get file size: %w", err)
 	}
 
-	// Telegram has a 50MB limit for files, but for GIFs it's usually 20MB
-	const maxGIFSize = 20 * 1024 * 1024
-	if fileSize > maxGIFSize {
+	if fileSize > domain.MaxOutputSize || (task.Format == domain.FormatSticker && fileSize > domain.MaxStickerSize) {
 		vp.sendError(task.ChatID, locale.ErrorFileTooBig, locale)
-		return fmt.Errorf("GIF file too large: %d bytes", fileSize)
+		return fmt.Errorf("output file too large: %d bytes", fileSize)
 	}
 
-	// Send GIF
-	if err := vp.bot.EditMessageText(task.ChatID, task.StatusMsgID, locale.SendingGIF); err != nil {
+	return nil
+}
+
+// sendOutput sends a converted animation and returns the message ID.
+// Telegram doesn't play WebP animations, so they are sent as files.
+// Stickers can't have captions.
+func (vp *VideoProcessor) sendOutput(task *domain.ProcessingTask, outputPath, caption string, keyboard [][]telegram.InlineButton) (int, error) {
+	switch task.Format {
+	case domain.FormatWebP:
+		return vp.bot.SendDocument(task.ChatID, outputPath, caption, keyboard)
+	case domain.FormatSticker:
+		return vp.bot.SendSticker(task.ChatID, outputPath, keyboard)
+	default:
+		return vp.bot.SendAnimation(task.ChatID, outputPath, caption, keyboard)
+	}
+}
+
+// rerenderKeyboard returns the buttons that convert a result again with
+// adjusted settings, leaving out the format the result already has
+func rerenderKeyboard(format domain.OutputFormat, locale *domain.Locale) [][]telegram.InlineButton {
+	formats := make([]telegram.InlineButton, 0, 2)
+	if format != domain.FormatMP4 {
+		formats = append(formats, telegram.InlineButton{Text: locale.ButtonAsMP4, Data: domain.CallbackRenderMP4})
+	}
+	if format != domain.FormatSticker {
+		formats = append(formats, telegram.InlineButton{Text: locale.ButtonAsSticker, Data: domain.CallbackRenderSticker})
+	}
+
+	return [][]telegram.InlineButton{
+		{
+			{Text: locale.ButtonSmaller, Data: domain.CallbackRenderSmaller},
+			{Text: locale.ButtonBetter, Data: domain.CallbackRenderBetter},
+			{Text: locale.ButtonFaster, Data: domain.CallbackRenderFaster},
+		},
+		formats,
+	}
+}
+
+// reserveQuota takes GIFs from the daily quota of a chat, telling the
+// user how many are left if there are not enough
+func (vp *VideoProcessor) reserveQuota(chatID int64, n int, locale *domain.Locale) bool {
+	if vp.quota.Reserve(chatID, n) {
+		return true
+	}
+	vp.sendError(chatID, fmt.Sprintf(locale.QuotaExceeded, vp.quota.Remaining(chatID), vp.quota.Limit()), locale)
+	return false
+}
+
+// downloadFile downloads a Telegram file to a local path
+func (vp *VideoProcessor) downloadFile(chatID int64, fileID, path string, locale *domain.Locale) error {
+	fileURL, err := vp.bot.GetFileLink(fileID)
+	if err != nil {
+		vp.sendError(chatID, locale.ErrorGetFile, locale)
+		return fmt.Errorf("failed to get file link: %w", err)
+	}
+
+	if err := vp.fileStore.DownloadFile(fileURL, path); err != nil {
+		vp.sendError(chatID, locale.ErrorDownload, locale)
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	return nil
+}
+
+// prepareSlideshow downloads album photos and renders them into a video
+// no longer than maxLength seconds
+func (vp *VideoProcessor) prepareSlideshow(task *domain.ProcessingTask, tempDir, videoPath string, maxLength float64, locale *domain.Locale) error {
+	photoPaths := make([]string, 0, len(task.PhotoFileIDs))
+	for i, fileID := range task.PhotoFileIDs {
+		photoPath := filepath.Join(tempDir, fmt.Sprintf("photo_%02d.jpg", i))
+		if err := vp.downloadFile(task.ChatID, fileID, photoPath, locale); err != nil {
+			return err
+		}
+		photoPaths = append(photoPaths, photoPath)
+	}
+
+	frameDuration := vp.config.Slideshow.FrameDuration
+	if frameDuration <= 0 {
+		frameDuration = defaultSlideshowFrameDuration
+	}
+	frameDuration, crossfade := fitSlideshow(len(photoPaths), frameDuration, vp.config.Slideshow.Crossfade, maxLength)
+
+	if err := vp.converter.CreateSlideshow(photoPaths, videoPath, frameDuration, crossfade); err != nil {
+		vp.sendError(task.ChatID, locale.ErrorSlideshow, locale)
+		return fmt.Errorf("failed to create slideshow: %w", err)
+	}
+
+	return nil
+}
+
+// fitSlideshow shortens the photos and the crossfades between them
+// proportionally so that a slideshow of the given number of photos plays no
+// longer than maxLength seconds
+func fitSlideshow(photos int, frameDuration, crossfade, maxLength float64) (float64, float64) {
+	// A crossfade can't be longer than the photo it fades from
+	if crossfade >= frameDuration {
+		crossfade = frameDuration / 2
+	}
+	crossfade = max(crossfade, 0)
+
+	// Crossfades overlap neighbouring photos
+	length := float64(photos)*frameDuration - float64(photos-1)*crossfade
+	if length <= maxLength || maxLength <= 0 {
+		return frameDuration, crossfade
+	}
+	scale := maxLength / length
+	return frameDuration * scale, crossfade * scale
+}
+
+// processVideoNote converts a downloaded video to a video note and sends it
+func (vp *VideoProcessor) processVideoNote(task *domain.ProcessingTask, videoPath, notePath string, locale *domain.Locale) error {
+	length, err := vp.converter.ConvertToVideoNote(videoPath, notePath)
+	if err != nil {
+		vp.sendError(task.ChatID, locale.ErrorConversion, locale)
+		return fmt.Errorf("failed to convert: %w", err)
+	}
+
+	if err := vp.bot.EditMessageText(task.ChatID, task.StatusMsgID, locale.SendingVideoNote); err != nil {
 		// Log error but continue
 	}
 
-	if err := vp.bot.SendAnimation(task.ChatID, gifPath, locale.GIFReady); err != nil {
-		vp.sendError(task.ChatID, locale.ErrorSendGIF, locale)
-		return fmt.Errorf("failed to send GIF: %w", err)
+	if err := vp.bot.SendVideoNote(task.ChatID, notePath, length); err != nil {
+		vp.sendError(task.ChatID, locale.ErrorSendVideoNote, locale)
+		return fmt.Errorf("failed to send video note: %w", err)
 	}
 
 	// Delete status message
@@ -119,7 +493,69 @@ func (vp *VideoProcessor) ProcessVideo(ctx context.Context, task *domain.Process
 	return nil
 }
 
+// canReconvert reports whether the video of a task can be downloaded and
+// converted again in another way. Album slideshows can't, and video notes
+// keep their own length limit.
+func (vp *VideoProcessor) canReconvert(task *domain.ProcessingTask) bool {
+	return task.VideoFileID != "" && task.Format != domain.FormatVideoNote
+}
+
+// fitOffers returns the buttons and the texts offering to fit a video that
+// is too long into the limit as a timelapse or a series of GIFs, if it is
+// short enough for that
+func (vp *VideoProcessor) fitOffers(config *domain.Config, duration float64, locale *domain.Locale) ([]telegram.InlineButton, []string) {
+	var buttons []telegram.InlineButton
+	var offers []string
+	if duration <= float64(vp.config.Processing.MaxTimelapseDuration) {
+		buttons = append(buttons, telegram.InlineButton{Text: locale.ButtonTimelapse, Data: domain.CallbackTimelapse})
+		offers = append(offers, locale.Plural(locale.TimelapseOffer, vp.config.Processing.MaxVideoDuration))
+	}
+	parts := config.GIF.SplitParts(duration, float64(vp.config.Processing.MaxVideoDuration))
+	if parts <= vp.config.Processing.MaxSplitParts {
+		buttons = append(buttons, telegram.InlineButton{Text: locale.ButtonSplit, Data: domain.CallbackSplit})
+		offers = append(offers, locale.Plural(locale.SplitOffer, parts))
+	}
+	return buttons, offers
+}
+
+// videoSource describes the video of a task for buttons on a bot message
+func (vp *VideoProcessor) videoSource(task *domain.ProcessingTask, duration float64, highlights []domain.TimeRange) domain.VideoSource {
+	return domain.VideoSource{
+		MessageID:    task.MessageID,
+		FileID:       task.VideoFileID,
+		PhotoFileIDs: task.PhotoFileIDs,
+		Format:       task.Format,
+		IsVideoNote:  task.IsVideoNote,
+		Options:      task.Options,
+		Duration:     duration,
+		Highlights:   highlights,
+	}
+}
+
+// taskConfig returns the conversion config for a task
+func (vp *VideoProcessor) taskConfig(task *domain.ProcessingTask) *domain.Config {
+	config := *vp.config
+	if task.Format == domain.FormatVideoNote {
+		return &config
+	}
+
+	// The circular mask only makes sense for round inputs
+	config.GIF.CircleMask = config.GIF.CircleMask && task.IsVideoNote
+
+	// Global defaults, then user settings or the preset named in the
+	// caption, then overrides from the message
+	task.Options.Settings(vp.settingsSvc.Get(task.ChatID)).Apply(&config.GIF)
+	task.Options.Apply(&config.GIF)
+
+	return &config
+}
+
+// formatClock formats a video position as minutes and seconds
+func formatClock(seconds float64) string {
+	total := int(seconds + 0.5)
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
 func (vp *VideoProcessor) sendError(chatID int64, message string, locale *domain.Locale) {
 	_, _ = vp.bot.SendMessage(chatID, fmt.Sprintf("❌ %s", message), nil)
 }
-
@@ -1,19 +1,138 @@
 package domain
 
+import (
+	"math"
+	"slices"
+)
+
+// GIFConfig represents GIF conversion settings
+type GIFConfig struct {
+	Quality    string      `yaml:"quality"`
+	FPS        int         `yaml:"fps"`
+	Width      int         `yaml:"width"`
+	Colors     int         `yaml:"colors"`
+	CircleMask bool        `yaml:"circle_mask"` // round videos get a transparent circular mask
+	FontPath   string      `yaml:"font_path"`   // TTF font for captions
+	AutoCrop   bool        `yaml:"auto_crop"`   // detect and remove black bars
+	Reframe    ReframeMode `yaml:"reframe"`     // change the aspect ratio of the output
+
+	Dither         DitherMode               `yaml:"dither"`          // used when the quality preset doesn't set one
+	BayerScale     int                      `yaml:"bayer_scale"`     // 0-5, lower values give a stronger pattern
+	PaletteMode    PaletteMode              `yaml:"palette_mode"`    // used when the quality preset doesn't set one
+	QualityPresets map[string]QualityPreset `yaml:"quality_presets"` // overrides the built-in presets
+	SceneThreshold float64                  `yaml:"scene_threshold"` // scene cut sensitivity for the scene palette mode
+
+	Optimize bool `yaml:"optimize"` // store only changed pixels of each frame
+	Lossy    int  `yaml:"lossy"`    // max color difference treated as unchanged, 0 is lossless
+
+	// Per-conversion settings, never read from the config file
+	TopText       string  `yaml:"-"`
+	BottomText    string  `yaml:"-"`
+	Speed         float64 `yaml:"-"` // playback speed, 0 means normal speed
+	Reverse       bool    `yaml:"-"` // play backwards
+	Boomerang     bool    `yaml:"-"` // play forward and then backward
+	PerfectLoop   bool    `yaml:"-"` // trim to frames that loop seamlessly
+	LoopCrossfade bool    `yaml:"-"` // blend the end into the start, set when no seamless loop is found
+	TrimStart     float64 `yaml:"-"` // seconds of the input skipped before converting
+	TrimEnd       float64 `yaml:"-"` // input position to stop at, 0 means the end
+}
+
+// ApplyQualityPreset applies the palette settings of the preset named by
+// Quality. Config file presets replace built-in ones of the same name.
+func (g *GIFConfig) ApplyQualityPreset() {
+	preset, ok := g.QualityPresets[g.Quality]
+	if !ok {
+		preset, ok = DefaultQualityPresets()[g.Quality]
+	}
+	if !ok {
+		return
+	}
+
+	if preset.Colors > 0 {
+		g.Colors = preset.Colors
+	}
+	if preset.Dither != "" {
+		g.Dither = preset.Dither
+		g.BayerScale = preset.BayerScale
+	}
+	if preset.PaletteMode != "" {
+		g.PaletteMode = preset.PaletteMode
+	}
+}
+
+// PlaybackSpeed returns the playback speed multiplier
+func (g GIFConfig) PlaybackSpeed() float64 {
+	if g.Speed <= 0 {
+		return 1
+	}
+	return g.Speed
+}
+
+// OutputDuration returns how long the result of converting a video of
+// the given duration plays with the playback effects applied
+func (g GIFConfig) OutputDuration(inputDuration float64) float64 {
+	duration := inputDuration / g.PlaybackSpeed()
+	if g.Boomerang {
+		duration *= 2
+	}
+	return duration
+}
+
+// FitDuration speeds playback up so that the result of converting a video
+// of the given duration plays no longer than maxDuration seconds
+func (g *GIFConfig) FitDuration(inputDuration, maxDuration float64) {
+	if outputDuration := g.OutputDuration(inputDuration); outputDuration > maxDuration {
+		g.Speed = g.PlaybackSpeed() * outputDuration / maxDuration
+	}
+}
+
+// SplitParts returns how many parts a video of the given duration has to be
+// split into so that each of them plays no longer than maxDuration seconds
+func (g GIFConfig) SplitParts(inputDuration, maxDuration float64) int {
+	return max(int(math.Ceil(g.OutputDuration(inputDuration)/maxDuration)), 1)
+}
+
+// AccessConfig restricts who can use the bot
+type AccessConfig struct {
+	AllowedUsers []int64 `yaml:"allowed_users"` // if set, only these users are served
+	BannedUsers  []int64 `yaml:"banned_users"`
+	RateLimit    int     `yaml:"rate_limit"` // updates per user per minute, 0 means unlimited
+}
+
+// Allows reports whether a user can use the bot
+func (a AccessConfig) Allows(userID int64) bool {
+	if slices.Contains(a.BannedUsers, userID) {
+		return false
+	}
+	return len(a.AllowedUsers) == 0 || slices.Contains(a.AllowedUsers, userID)
+}
+
 // Config represents application configuration
 type Config struct {
 	Bot struct {
-		Token string `yaml:"token"`
+		Token           string `yaml:"token"`
+		DefaultLanguage string `yaml:"default_language"` // for users whose Telegram language is not supported
+		LocalesDir      string `yaml:"locales_dir"`      // extra locale files, added to the built-in ones
 	} `yaml:"bot"`
-	GIF struct {
-		Quality string `yaml:"quality"`
-		FPS     int    `yaml:"fps"`
-		Width   int    `yaml:"width"`
-		Colors  int    `yaml:"colors"`
-	} `yaml:"gif"`
+	GIF       GIFConfig               `yaml:"gif"`
+	Presets   map[string]UserSettings `yaml:"presets"` // admin presets available to every user
+	Slideshow struct {
+		FrameDuration float64 `yaml:"frame_duration"` // seconds each photo is shown
+		Crossfade     float64 `yaml:"crossfade"`      // crossfade length in seconds, 0 disables it
+	} `yaml:"slideshow"`
 	Processing struct {
-		MaxConcurrent    int `yaml:"max_concurrent"`
-		MaxVideoDuration int `yaml:"max_video_duration"`
+		MaxConcurrent        int `yaml:"max_concurrent"`
+		MaxVideoDuration     int `yaml:"max_video_duration"`
+		MaxTimelapseDuration int `yaml:"max_timelapse_duration"` // longer videos can't be sped up, 0 disables timelapses
+		MaxSplitParts        int `yaml:"max_split_parts"`        // most GIFs a video is split into, 0 disables splitting
+		DailyQuota           int `yaml:"daily_quota"`            // GIFs per chat per day, 0 means unlimited
 	} `yaml:"processing"`
+	Updates struct {
+		Workers int `yaml:"workers"` // updates handled at once, each chat's updates are handled one by one
+		Backlog int `yaml:"backlog"` // most updates waiting to be handled, newer ones are dropped
+	} `yaml:"updates"`
+	Access  AccessConfig `yaml:"access"`
+	Storage struct {
+		DataDir string `yaml:"data_dir"` // user settings are kept here, "data" by default
+	} `yaml:"storage"`
 }
-
@@ -0,0 +1,121 @@
+package domain
+
+// ConversionOptions holds per-message overrides for a single conversion
+type ConversionOptions struct {
+	TopText    string
+	BottomText string
+	Speed      float64 // 0 keeps the user's speed
+	Reverse    *bool   // nil keeps the user's setting
+	Boomerang  *bool   // nil keeps the user's setting
+	Loop       *bool   // make the GIF loop seamlessly, nil keeps the user's setting
+	Timelapse  bool    // speed up to fit the duration limit, applied with FitDuration
+	Split      bool    // convert into a series of GIFs that fit the duration limit
+	Highlight  bool    // convert the most active part that fits the duration limit
+	Trim       TimeRange
+	FPS        int           // 0 keeps the configured frame rate
+	Width      int           // 0 keeps the configured width
+	Quality    string        // empty keeps the user's quality preset
+	Format     OutputFormat  // empty keeps the user's format
+	Preset     string        // name of the preset used instead of the user's settings
+	PresetUsed *UserSettings // the preset found by name, nil uses the user's settings
+	Customize  bool          // ask for settings before converting
+}
+
+// TimeRange is a part of a video in seconds. End 0 means the end of the
+// video, so the zero value is the whole video.
+type TimeRange struct {
+	Start float64
+	End   float64
+}
+
+// IsSet reports whether the range cuts anything off
+func (r TimeRange) IsSet() bool {
+	return r.Start > 0 || r.End > 0
+}
+
+// Bounds returns the start and end of the range in a video of the given duration
+func (r TimeRange) Bounds(duration float64) (float64, float64) {
+	start := min(max(r.Start, 0), duration)
+	end := duration
+	if r.End > 0 {
+		end = min(r.End, duration)
+	}
+	return start, max(end, start)
+}
+
+// Settings returns the user settings the options are applied on top of.
+// A quality from the message replaces the saved one here, so the quality
+// preset is applied once, on the global palette settings.
+func (o ConversionOptions) Settings(saved UserSettings) UserSettings {
+	settings := saved
+	if o.PresetUsed != nil {
+		settings = *o.PresetUsed
+	}
+	if o.Quality != "" {
+		settings.Quality = o.Quality
+	}
+	return settings
+}
+
+// Apply applies the per-message overrides on top of user settings.
+// Captions replace the saved ones, effects that are set turn the saved
+// ones on or off.
+func (o ConversionOptions) Apply(gif *GIFConfig) {
+	if o.HasText() {
+		gif.TopText, gif.BottomText = o.TopText, o.BottomText
+	}
+	if o.Speed > 0 {
+		gif.Speed = o.Speed
+	}
+	if o.Reverse != nil {
+		gif.Reverse = *o.Reverse
+	}
+	if o.Boomerang != nil {
+		gif.Boomerang = *o.Boomerang
+	}
+	if o.Loop != nil {
+		gif.PerfectLoop = *o.Loop
+	}
+	if o.FPS > 0 {
+		gif.FPS = o.FPS
+	}
+	if o.Width > 0 {
+		gif.Width = o.Width
+	}
+	if o.Trim.IsSet() {
+		gif.TrimStart, gif.TrimEnd = o.Trim.Start, o.Trim.End
+	}
+}
+
+// Playback speed limits for the speed effect
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 4.0
+)
+
+// IsValidSpeed reports whether a playback speed is within the allowed range
+func IsValidSpeed(speed float64) bool {
+	return speed >= MinSpeed && speed <= MaxSpeed
+}
+
+// Frame rate and width limits for per-message overrides
+const (
+	MaxFPS   = 30
+	MinWidth = 32
+	MaxWidth = 1280
+)
+
+// IsValidFPS reports whether a frame rate is within the allowed range
+func IsValidFPS(fps int) bool {
+	return fps >= 1 && fps <= MaxFPS
+}
+
+// IsValidWidth reports whether an output width is within the allowed range
+func IsValidWidth(width int) bool {
+	return width >= MinWidth && width <= MaxWidth
+}
+
+// HasText reports whether the options set meme captions
+func (o ConversionOptions) HasText() bool {
+	return o.TopText != "" || o.BottomText != ""
+}
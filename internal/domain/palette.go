@@ -0,0 +1,110 @@
+package domain
+
+// DitherMode is the dithering algorithm used when mapping frames to the palette
+type DitherMode string
+
+const (
+	DitherNone           DitherMode = "none"
+	DitherBayer          DitherMode = "bayer"
+	DitherFloydSteinberg DitherMode = "floyd_steinberg"
+	DitherSierra2        DitherMode = "sierra2"
+	DitherSierra24A      DitherMode = "sierra2_4a"
+)
+
+// DitherModes lists all dithering modes in display order
+var DitherModes = []DitherMode{
+	DitherNone,
+	DitherBayer,
+	DitherFloydSteinberg,
+	DitherSierra2,
+	DitherSierra24A,
+}
+
+// IsValid reports whether the mode is a known dithering mode
+func (m DitherMode) IsValid() bool {
+	for _, mode := range DitherModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// PaletteMode describes how palettes are built for a GIF
+type PaletteMode string
+
+const (
+	// PaletteGlobal builds one palette from all pixels of the clip
+	PaletteGlobal PaletteMode = "global"
+	// PaletteDiff builds one palette favoring pixels that change between frames
+	PaletteDiff PaletteMode = "diff"
+	// PaletteSingle builds a new palette for every frame
+	PaletteSingle PaletteMode = "single"
+	// PaletteScene builds a palette for every scene between cuts
+	PaletteScene PaletteMode = "scene"
+)
+
+// PaletteModes lists all palette modes in display order
+var PaletteModes = []PaletteMode{
+	PaletteGlobal,
+	PaletteDiff,
+	PaletteSingle,
+	PaletteScene,
+}
+
+// IsValid reports whether the mode is a known palette mode
+func (m PaletteMode) IsValid() bool {
+	for _, mode := range PaletteModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultSceneThreshold is the scene score above which a frame starts a new scene
+const DefaultSceneThreshold = 0.3
+
+// Bayer scale limits supported by paletteuse
+const (
+	MinBayerScale = 0
+	MaxBayerScale = 5
+)
+
+// QualityPreset is a named set of palette settings
+type QualityPreset struct {
+	Colors      int         `yaml:"colors"`       // 0 keeps gif.colors
+	Dither      DitherMode  `yaml:"dither"`       // empty keeps gif.dither
+	BayerScale  int         `yaml:"bayer_scale"`  // used with bayer dithering
+	PaletteMode PaletteMode `yaml:"palette_mode"` // empty keeps gif.palette_mode
+}
+
+// Quality preset names
+const (
+	QualityLow    = "low"
+	QualityMedium = "medium"
+	QualityHigh   = "high"
+)
+
+// QualityLevels lists quality presets in display order
+var QualityLevels = []string{QualityLow, QualityMedium, QualityHigh}
+
+// DefaultQualityPresets returns the built-in quality presets
+func DefaultQualityPresets() map[string]QualityPreset {
+	return map[string]QualityPreset{
+		QualityLow: {
+			Colors:      64,
+			Dither:      DitherBayer,
+			BayerScale:  3,
+			PaletteMode: PaletteGlobal,
+		},
+		QualityMedium: {
+			Dither:      DitherSierra24A,
+			PaletteMode: PaletteGlobal,
+		},
+		QualityHigh: {
+			Dither:      DitherFloydSteinberg,
+			PaletteMode: PaletteSingle,
+		},
+	}
+}
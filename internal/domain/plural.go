@@ -0,0 +1,75 @@
+package domain
+
+import "fmt"
+
+// PluralCategory is a CLDR plural category
+type PluralCategory string
+
+const (
+	PluralOne   PluralCategory = "one"
+	PluralFew   PluralCategory = "few"
+	PluralMany  PluralCategory = "many"
+	PluralOther PluralCategory = "other"
+)
+
+// PluralForms holds the forms of a count-based message by plural category.
+// Every form has a single %d verb for the count.
+type PluralForms map[PluralCategory]string
+
+// PluralCategories returns the categories a language uses for whole numbers,
+// other is always among them
+func PluralCategories(lang string) []PluralCategory {
+	switch lang {
+	case "ru", "uk":
+		return []PluralCategory{PluralOne, PluralFew, PluralMany, PluralOther}
+	case "es":
+		return []PluralCategory{PluralOne, PluralMany, PluralOther}
+	default:
+		return []PluralCategory{PluralOne, PluralOther}
+	}
+}
+
+// PluralCategoryFor returns the CLDR plural category of a whole number in
+// a language. Languages without their own rules use the English ones.
+func PluralCategoryFor(lang string, n int) PluralCategory {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case "ru", "uk":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "es":
+		switch {
+		case n == 1:
+			return PluralOne
+		case n != 0 && n%1000000 == 0:
+			return PluralMany
+		default:
+			return PluralOther
+		}
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}
+}
+
+// Plural formats the form of a count-based message that matches the count
+// in the language of the locale, using the other form when it is missing
+func (l *Locale) Plural(forms PluralForms, n int) string {
+	form, ok := forms[PluralCategoryFor(l.Code, n)]
+	if !ok {
+		form = forms[PluralOther]
+	}
+	return fmt.Sprintf(form, n)
+}
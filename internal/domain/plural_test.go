@@ -0,0 +1,92 @@
+package domain
+
+import "testing"
+
+func TestPluralCategoryFor(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want PluralCategory
+	}{
+		{"ru", 0, PluralMany},
+		{"ru", 1, PluralOne},
+		{"ru", 2, PluralFew},
+		{"ru", 4, PluralFew},
+		{"ru", 5, PluralMany},
+		{"ru", 11, PluralMany},
+		{"ru", 12, PluralMany},
+		{"ru", 14, PluralMany},
+		{"ru", 21, PluralOne},
+		{"ru", 22, PluralFew},
+		{"ru", 25, PluralMany},
+		{"ru", 111, PluralMany},
+		{"ru", 112, PluralMany},
+		{"ru", 101, PluralOne},
+		{"ru", -1, PluralOne},
+		{"uk", 0, PluralMany},
+		{"uk", 1, PluralOne},
+		{"uk", 3, PluralFew},
+		{"uk", 11, PluralMany},
+		{"uk", 13, PluralMany},
+		{"uk", 21, PluralOne},
+		{"uk", 23, PluralFew},
+		{"es", 0, PluralOther},
+		{"es", 1, PluralOne},
+		{"es", 2, PluralOther},
+		{"es", 21, PluralOther},
+		{"es", 1000000, PluralMany},
+		{"es", 2000000, PluralMany},
+		{"es", 1000001, PluralOther},
+		{"en", 0, PluralOther},
+		{"en", 1, PluralOne},
+		{"en", 2, PluralOther},
+		{"en", 21, PluralOther},
+		{"de", 1, PluralOne}, // languages without rules use the English ones
+		{"de", 5, PluralOther},
+	}
+
+	for _, tt := range tests {
+		if got := PluralCategoryFor(tt.lang, tt.n); got != tt.want {
+			t.Errorf("PluralCategoryFor(%q, %d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPluralCategoriesCoverCategoryFor(t *testing.T) {
+	for _, lang := range []string{"ru", "uk", "es", "en"} {
+		categories := make(map[PluralCategory]bool)
+		for _, category := range PluralCategories(lang) {
+			categories[category] = true
+		}
+		for n := 0; n <= 1000; n++ {
+			if category := PluralCategoryFor(lang, n); !categories[category] {
+				t.Errorf("PluralCategoryFor(%q, %d) = %q, which PluralCategories doesn't list", lang, n, category)
+			}
+		}
+	}
+}
+
+func TestLocalePlural(t *testing.T) {
+	forms := PluralForms{
+		PluralOne:   "%d секунда",
+		PluralFew:   "%d секунды",
+		PluralOther: "%d секунд",
+	}
+	locale := &Locale{Code: "ru"}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{1, "1 секунда"},
+		{3, "3 секунды"},
+		{11, "11 секунд"}, // the missing many form falls back to other
+		{21, "21 секунда"},
+	}
+
+	for _, tt := range tests {
+		if got := locale.Plural(forms, tt.n); got != tt.want {
+			t.Errorf("Plural(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
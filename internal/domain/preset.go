@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// MaxUserPresets is how many presets a user can save
+const MaxUserPresets = 20
+
+// presetNamePattern limits preset names to what fits into captions and
+// callback data: "reaction-small", "hq_loop"
+var presetNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// IsValidPresetName reports whether a preset name can be used
+func IsValidPresetName(name string) bool {
+	return presetNamePattern.MatchString(name)
+}
+
+// PresetStore stores named settings presets saved by users
+type PresetStore struct {
+	mu      sync.RWMutex
+	presets map[int64]map[string]UserSettings // chatID -> name -> settings
+}
+
+// NewPresetStore creates a new PresetStore instance
+func NewPresetStore() *PresetStore {
+	return &PresetStore{
+		presets: make(map[int64]map[string]UserSettings),
+	}
+}
+
+// Get returns a preset of a chat by name
+func (s *PresetStore) Get(chatID int64, name string) (UserSettings, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	preset, ok := s.presets[chatID][name]
+	return preset, ok
+}
+
+// Names returns the sorted preset names of a chat
+func (s *PresetStore) Names(chatID int64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.presets[chatID]))
+	for name := range s.presets[chatID] {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Put saves a preset, replacing one with the same name. It returns false
+// if the chat already has the maximum number of presets.
+func (s *PresetStore) Put(chatID int64, name string, preset UserSettings) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	presets := s.presets[chatID]
+	if presets == nil {
+		presets = make(map[string]UserSettings)
+		s.presets[chatID] = presets
+	}
+	if _, exists := presets[name]; !exists && len(presets) >= MaxUserPresets {
+		return false
+	}
+	presets[name] = preset
+	return true
+}
+
+// Delete removes a preset and reports whether it existed
+func (s *PresetStore) Delete(chatID int64, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.presets[chatID][name]; !ok {
+		return false
+	}
+	delete(s.presets[chatID], name)
+	if len(s.presets[chatID]) == 0 {
+		delete(s.presets, chatID)
+	}
+	return true
+}
+
+// All returns a copy of the presets of every chat
+func (s *PresetStore) All() map[int64]map[string]UserSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[int64]map[string]UserSettings, len(s.presets))
+	for chatID, presets := range s.presets {
+		all[chatID] = maps.Clone(presets)
+	}
+	return all
+}
+
+// Load replaces the stored presets, used to restore them on startup
+func (s *PresetStore) Load(presets map[int64]map[string]UserSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.presets = make(map[int64]map[string]UserSettings, len(presets))
+	for chatID, named := range presets {
+		s.presets[chatID] = maps.Clone(named)
+	}
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// dailyUsage counts the GIFs a chat received on a day
+type dailyUsage struct {
+	day   string
+	count int
+}
+
+// UsageQuota limits how many GIFs each chat gets per day
+type UsageQuota struct {
+	mu    sync.Mutex
+	limit int // 0 means unlimited
+	usage map[int64]dailyUsage
+}
+
+// NewUsageQuota creates a new UsageQuota instance
+func NewUsageQuota(limit int) *UsageQuota {
+	return &UsageQuota{
+		limit: limit,
+		usage: make(map[int64]dailyUsage),
+	}
+}
+
+// Limit returns the daily limit, 0 means unlimited
+func (q *UsageQuota) Limit() int {
+	return q.limit
+}
+
+// Remaining returns how many GIFs a chat can still get today
+func (q *UsageQuota) Remaining(chatID int64) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.limit - q.today(chatID).count
+}
+
+// Reserve takes n GIFs from the quota of a chat if enough are left
+func (q *UsageQuota) Reserve(chatID int64, n int) bool {
+	if q.limit <= 0 {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage := q.today(chatID)
+	if usage.count+n > q.limit {
+		return false
+	}
+	usage.count += n
+	q.usage[chatID] = usage
+	return true
+}
+
+// Release returns n reserved GIFs that were not sent
+func (q *UsageQuota) Release(chatID int64, n int) {
+	if q.limit <= 0 || n <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage := q.today(chatID)
+	usage.count = max(usage.count-n, 0)
+	q.usage[chatID] = usage
+}
+
+// today returns the usage of a chat, reset when the day changes
+func (q *UsageQuota) today(chatID int64) dailyUsage {
+	day := time.Now().Format(time.DateOnly)
+	usage := q.usage[chatID]
+	if usage.day != day {
+		usage = dailyUsage{day: day}
+	}
+	return usage
+}
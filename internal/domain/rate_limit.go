@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// rateBucket holds the updates a user can still send right away
+type rateBucket struct {
+	tokens   float64
+	updated  time.Time
+	rejected bool // the last update was rejected
+
+	// Album messages come as separate updates, the whole album shares the
+	// decision made for its first message
+	mediaGroup   string
+	groupAllowed bool
+}
+
+// RateLimiter limits how many updates each user sends per minute. Users
+// can send the whole limit at once, then the allowance refills evenly.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int // per minute, 0 means unlimited
+	buckets   map[int64]*rateBucket
+	lastPrune time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter instance
+func NewRateLimiter(limit int) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		buckets: make(map[int64]*rateBucket),
+	}
+}
+
+// Allow takes an update from the allowance of a user. Notify is true for
+// the first rejected update in a row, so the user is told about the limit once.
+// Updates with the media group ID of the previous one are part of the same
+// album and count as one update.
+func (l *RateLimiter) Allow(userID int64, mediaGroupID string) (allowed, notify bool) {
+	if l.limit <= 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &rateBucket{tokens: float64(l.limit), updated: now}
+		l.buckets[userID] = bucket
+	}
+	if mediaGroupID != "" && mediaGroupID == bucket.mediaGroup {
+		return bucket.groupAllowed, false
+	}
+	bucket.mediaGroup = mediaGroupID
+
+	bucket.tokens = min(bucket.tokens+now.Sub(bucket.updated).Minutes()*float64(l.limit), float64(l.limit))
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		notify = !bucket.rejected
+		bucket.rejected = true
+		bucket.groupAllowed = false
+		return false, notify
+	}
+	bucket.tokens--
+	bucket.rejected = false
+	bucket.groupAllowed = true
+	return true, false
+}
+
+// prune drops the buckets that refilled completely, they are the same as
+// new ones
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	for userID, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= time.Minute {
+			delete(l.buckets, userID)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package domain
+
+// ReframeMode describes how a video is fitted into another aspect ratio
+type ReframeMode string
+
+const (
+	// ReframeNone keeps the original aspect ratio
+	ReframeNone ReframeMode = "none"
+	// ReframeSquare center-crops to 1:1
+	ReframeSquare ReframeMode = "square"
+	// ReframeVertical center-crops to 9:16
+	ReframeVertical ReframeMode = "vertical"
+	// ReframeSquareBlur pads to 1:1 over a blurred copy of the video
+	ReframeSquareBlur ReframeMode = "square_blur"
+	// ReframeVerticalBlur pads to 9:16 over a blurred copy of the video
+	ReframeVerticalBlur ReframeMode = "vertical_blur"
+)
+
+// ReframeModes lists all reframe modes in display order
+var ReframeModes = []ReframeMode{
+	ReframeNone,
+	ReframeSquare,
+	ReframeVertical,
+	ReframeSquareBlur,
+	ReframeVerticalBlur,
+}
+
+// IsValid reports whether the mode is a known reframe mode
+func (m ReframeMode) IsValid() bool {
+	for _, mode := range ReframeModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Aspect returns the target aspect ratio as width and height parts.
+// It reports false for modes that keep the original aspect ratio.
+func (m ReframeMode) Aspect() (int, int, bool) {
+	switch m {
+	case ReframeSquare, ReframeSquareBlur:
+		return 1, 1, true
+	case ReframeVertical, ReframeVerticalBlur:
+		return 9, 16, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// IsBlurPad reports whether the mode pads over a blurred background
+// instead of cropping
+func (m ReframeMode) IsBlurPad() bool {
+	return m == ReframeSquareBlur || m == ReframeVerticalBlur
+}
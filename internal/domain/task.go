@@ -2,15 +2,58 @@ package domain
 
 import "context"
 
+// OutputFormat describes what a task produces from its input
+type OutputFormat string
+
+const (
+	// FormatGIF produces an animated GIF
+	FormatGIF OutputFormat = "gif"
+	// FormatVideoNote produces a square round video (video note)
+	FormatVideoNote OutputFormat = "videonote"
+	// FormatMP4 produces a silent H.264 video that Telegram plays like a GIF
+	FormatMP4 OutputFormat = "mp4"
+	// FormatWebP produces an animated WebP image
+	FormatWebP OutputFormat = "webp"
+	// FormatSticker produces a VP9 WebM video sticker
+	FormatSticker OutputFormat = "sticker"
+)
+
+// Largest outputs sent to Telegram. Telegram has a 50MB limit for files,
+// but for GIFs it's usually 20MB.
+const (
+	MaxOutputSize  = 20 * 1024 * 1024
+	MaxStickerSize = 256 * 1024
+)
+
+// AnimationFormats are the formats that can be picked for an animation
+var AnimationFormats = []OutputFormat{FormatGIF, FormatMP4, FormatWebP}
+
+// Extension returns the file extension of the format without a dot
+func (f OutputFormat) Extension() string {
+	switch f {
+	case FormatVideoNote, FormatMP4:
+		return "mp4"
+	case FormatWebP:
+		return "webp"
+	case FormatSticker:
+		return "webm"
+	default:
+		return "gif"
+	}
+}
+
 // ProcessingTask represents a video processing task
 type ProcessingTask struct {
 	ID            int
 	MessageID     int
 	ChatID        int64
 	VideoFileID   string
+	PhotoFileIDs  []string // album photos rendered as a slideshow instead of a video
 	StatusMsgID   int
 	QueuePosition int
+	Format        OutputFormat
+	IsVideoNote   bool // input is a round video
+	Options       ConversionOptions
 	CancelContext context.Context
 	CancelFunc    context.CancelFunc
 }
-
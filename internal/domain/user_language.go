@@ -1,6 +1,9 @@
 package domain
 
-import "sync"
+import (
+	"maps"
+	"sync"
+)
 
 // UserLanguage stores user language preferences
 type UserLanguage struct {
@@ -15,15 +18,12 @@ func NewUserLanguage() *UserLanguage {
 	}
 }
 
-// Get returns the language for a chat ID
-func (ul *UserLanguage) Get(chatID int64) string {
+// Get returns the language for a chat ID and whether it was set
+func (ul *UserLanguage) Get(chatID int64) (string, bool) {
 	ul.mu.RLock()
 	defer ul.mu.RUnlock()
 	lang, ok := ul.langs[chatID]
-	if !ok {
-		return "ru" // default language
-	}
-	return lang
+	return lang, ok
 }
 
 // Set sets the language for a chat ID
@@ -33,3 +33,32 @@ func (ul *UserLanguage) Set(chatID int64, lang string) {
 	ul.langs[chatID] = lang
 }
 
+// SetIfMissing sets the language for a chat ID that has none yet and
+// reports whether it was set
+func (ul *UserLanguage) SetIfMissing(chatID int64, lang string) bool {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	if _, ok := ul.langs[chatID]; ok {
+		return false
+	}
+	ul.langs[chatID] = lang
+	return true
+}
+
+// All returns a copy of the languages of every chat
+func (ul *UserLanguage) All() map[int64]string {
+	ul.mu.RLock()
+	defer ul.mu.RUnlock()
+	return maps.Clone(ul.langs)
+}
+
+// Load replaces the stored languages, used to restore them on startup
+func (ul *UserLanguage) Load(langs map[int64]string) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	ul.langs = maps.Clone(langs)
+	if ul.langs == nil {
+		ul.langs = make(map[int64]string)
+	}
+}
+
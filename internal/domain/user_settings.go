@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"maps"
+	"sync"
+)
+
+// UserSettings holds per-user conversion preferences. Presets use the
+// same fields, admin presets are read from the config file.
+type UserSettings struct {
+	TopText    string      `json:"top_text,omitempty" yaml:"top_text"`
+	BottomText string      `json:"bottom_text,omitempty" yaml:"bottom_text"`
+	Speed      float64     `json:"speed,omitempty" yaml:"speed"` // 0 means normal speed
+	Reverse    bool        `json:"reverse,omitempty" yaml:"reverse"`
+	Boomerang  bool        `json:"boomerang,omitempty" yaml:"boomerang"`
+	Loop       bool        `json:"loop,omitempty" yaml:"loop"`
+	AutoCrop   *bool       `json:"auto_crop,omitempty" yaml:"auto_crop"` // nil keeps the global default
+	Reframe    ReframeMode `json:"reframe,omitempty" yaml:"reframe"`     // empty keeps the global default
+	Customize  bool        `json:"customize,omitempty" yaml:"customize"` // ask for settings before every conversion
+
+	// Zero values keep the global defaults
+	FPS    int          `json:"fps,omitempty" yaml:"fps"`
+	Width  int          `json:"width,omitempty" yaml:"width"`
+	Format OutputFormat `json:"format,omitempty" yaml:"format"` // format of animations, a message can still ask for another one
+
+	// Empty values keep the quality preset and global defaults
+	Quality     string      `json:"quality,omitempty" yaml:"quality"`
+	Dither      DitherMode  `json:"dither,omitempty" yaml:"dither"`
+	PaletteMode PaletteMode `json:"palette_mode,omitempty" yaml:"palette_mode"`
+}
+
+// AutoCropEnabled reports whether black bars are removed for the user
+func (s UserSettings) AutoCropEnabled(defaultValue bool) bool {
+	if s.AutoCrop == nil {
+		return defaultValue
+	}
+	return *s.AutoCrop
+}
+
+// Apply applies the user settings on top of global GIF settings
+func (s UserSettings) Apply(gif *GIFConfig) {
+	gif.TopText, gif.BottomText = s.TopText, s.BottomText
+	gif.Speed = s.Speed
+	gif.Reverse = s.Reverse
+	gif.Boomerang = s.Boomerang
+	gif.PerfectLoop = s.Loop
+	gif.AutoCrop = s.AutoCropEnabled(gif.AutoCrop)
+	if s.Reframe != "" {
+		gif.Reframe = s.Reframe
+	}
+	if s.FPS > 0 {
+		gif.FPS = s.FPS
+	}
+	if s.Width > 0 {
+		gif.Width = s.Width
+	}
+
+	// The quality preset sets palette defaults, explicit choices win over it
+	if s.Quality != "" {
+		gif.Quality = s.Quality
+	}
+	gif.ApplyQualityPreset()
+	if s.Dither != "" {
+		gif.Dither = s.Dither
+	}
+	if s.PaletteMode != "" {
+		gif.PaletteMode = s.PaletteMode
+	}
+}
+
+// UserSettingsStore stores user conversion preferences
+type UserSettingsStore struct {
+	mu       sync.RWMutex
+	settings map[int64]UserSettings // chatID -> settings
+}
+
+// NewUserSettingsStore creates a new UserSettingsStore instance
+func NewUserSettingsStore() *UserSettingsStore {
+	return &UserSettingsStore{
+		settings: make(map[int64]UserSettings),
+	}
+}
+
+// Get returns the settings for a chat ID
+func (s *UserSettingsStore) Get(chatID int64) UserSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.settings[chatID]
+}
+
+// Set sets the settings for a chat ID
+func (s *UserSettingsStore) Set(chatID int64, settings UserSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[chatID] = settings
+}
+
+// All returns a copy of the settings of every chat
+func (s *UserSettingsStore) All() map[int64]UserSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.settings)
+}
+
+// Load replaces the stored settings, used to restore them on startup
+func (s *UserSettingsStore) Load(settings map[int64]UserSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = maps.Clone(settings)
+	if s.settings == nil {
+		s.settings = make(map[int64]UserSettings)
+	}
+}
+
+// Update atomically modifies the settings for a chat ID
+func (s *UserSettingsStore) Update(chatID int64, update func(settings *UserSettings)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	settings := s.settings[chatID]
+	update(&settings)
+	s.settings[chatID] = settings
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// videoSourceTTL is how long buttons on bot messages can reuse a video
+const videoSourceTTL = 24 * time.Hour
+
+// Callback data of buttons acting on a stored video source
+const (
+	CallbackTimelapse = "src_timelapse"
+	CallbackSplit     = "src_split"
+	CallbackHighlight = "src_highlight"
+
+	CallbackRenderSmaller = "src_smaller"
+	CallbackRenderBetter  = "src_better"
+	CallbackRenderFaster  = "src_faster"
+	CallbackRenderMP4     = "src_mp4"
+	CallbackRenderSticker = "src_sticker"
+
+	// CallbackHighlightPrefix is followed by the index of a runner-up highlight
+	CallbackHighlightPrefix = "src_highlight_"
+)
+
+// VideoSource remembers which video a bot message refers to, so buttons
+// on that message can convert the same video again
+type VideoSource struct {
+	MessageID    int // message the video was sent in
+	FileID       string
+	PhotoFileIDs []string // album photos of a slideshow
+	Format       OutputFormat
+	IsVideoNote  bool
+	Options      ConversionOptions
+	Duration     float64     // seconds
+	Highlights   []TimeRange // best part first, then the runner-ups
+
+	// Settings a result was converted with, zero for rejection messages
+	FPS     int
+	Width   int
+	Quality string
+	Speed   float64
+
+	expiresAt time.Time
+}
+
+// videoSourceKey identifies a bot message
+type videoSourceKey struct {
+	chatID    int64
+	messageID int
+}
+
+// VideoSourceStore stores video sources of bot messages for a limited time
+type VideoSourceStore struct {
+	mu      sync.Mutex
+	sources map[videoSourceKey]VideoSource
+}
+
+// NewVideoSourceStore creates a new VideoSourceStore instance
+func NewVideoSourceStore() *VideoSourceStore {
+	return &VideoSourceStore{
+		sources: make(map[videoSourceKey]VideoSource),
+	}
+}
+
+// Put stores the video source of a bot message
+func (s *VideoSourceStore) Put(chatID int64, messageID int, source VideoSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, stored := range s.sources {
+		if now.After(stored.expiresAt) {
+			delete(s.sources, key)
+		}
+	}
+
+	source.expiresAt = now.Add(videoSourceTTL)
+	s.sources[videoSourceKey{chatID: chatID, messageID: messageID}] = source
+}
+
+// Get returns the video source of a bot message if it hasn't expired
+func (s *VideoSourceStore) Get(chatID int64, messageID int) (VideoSource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.sources[videoSourceKey{chatID: chatID, messageID: messageID}]
+	if !ok || time.Now().After(source.expiresAt) {
+		return VideoSource{}, false
+	}
+	return source, true
+}
@@ -1,13 +1,43 @@
 package ffmpeg
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
 	"gifmaker-bot/internal/domain"
 )
 
+// maxVideoNoteSize is the largest side Telegram accepts for video notes
+const maxVideoNoteSize = 640
+
+// maxSlideshowSize is the longest side of a slideshow canvas
+const maxSlideshowSize = 1280
+
+// slideshowFPS is the frame rate of the intermediate slideshow video
+const slideshowFPS = 25
+
+// Telegram video sticker limits
+const (
+	stickerSize        = 512 // longest side in pixels
+	stickerMaxDuration = 3   // seconds
+	stickerBitrate     = "500k"
+)
+
+// webpQuality is the lossy quality of animated WebP output (0-100)
+const webpQuality = 75
+
+// circleMaskFilter makes everything outside the inscribed circle transparent
+const circleMaskFilter = "format=rgba,geq=r='r(X,Y)':g='g(X,Y)':b='b(X,Y)':" +
+	"a='if(lte(hypot(X-W/2,Y-H/2),min(W,H)/2),255,0)'"
+
+// boomerangFilter appends a reversed copy of the clip for a ping-pong loop
+const boomerangFilter = "split[fwd][rev];[rev]reverse[bwd];[fwd][bwd]concat=n=2:v=1:a=0"
+
 // Converter handles video to GIF conversion using FFmpeg
 type Converter struct{}
 
@@ -34,20 +64,153 @@ func (c *Converter) GetVideoDuration(videoPath string) (float64, error) {
 	return duration, nil
 }
 
+// GetVideoSize returns the width and height of the first video stream as
+// the filters see it. ffmpeg rotates videos by their rotation metadata
+// before filtering, so portrait phone videos stored sideways are swapped.
+func (c *Converter) GetVideoSize(videoPath string) (int, int, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries",
+		"stream=width,height:stream_tags=rotate:stream_side_data=rotation", "-of", "json", videoPath)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get video size: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+			Tags   struct {
+				Rotate string `json:"rotate"`
+			} `json:"tags"`
+			SideData []struct {
+				Rotation float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse video size: %w", err)
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].Width <= 0 || probe.Streams[0].Height <= 0 {
+		return 0, 0, fmt.Errorf("no video stream in %s", videoPath)
+	}
+
+	stream := probe.Streams[0]
+	rotation, _ := strconv.ParseFloat(stream.Tags.Rotate, 64)
+	for _, sideData := range stream.SideData {
+		if sideData.Rotation != 0 {
+			rotation = sideData.Rotation
+		}
+	}
+	if quarterTurns := int(math.Round(rotation / 90)); quarterTurns%2 != 0 {
+		return stream.Height, stream.Width, nil
+	}
+	return stream.Width, stream.Height, nil
+}
+
 // ConvertToGIF converts a video file to GIF
 func (c *Converter) ConvertToGIF(videoPath, outputPath string, config *domain.Config) error {
-	// Build scale filter based on width setting
-	var scaleFilter string
-	if config.GIF.Width > 0 {
-		scaleFilter = fmt.Sprintf("scale=%d:-1:flags=lanczos", config.GIF.Width)
-	} else {
-		scaleFilter = "scale=-1:-1:flags=lanczos"
+	filterChain, err := c.buildFilterChain(videoPath, config)
+	if err != nil {
+		return err
+	}
+
+	if config.GIF.PaletteMode == domain.PaletteScene {
+		return c.convertScenes(videoPath, outputPath, filterChain, &config.GIF)
+	}
+
+	return c.encodeGIF(videoPath, outputPath, filterChain, &config.GIF)
+}
+
+// ConvertToMP4 converts a video file to a silent H.264 video with the
+// same filters as a GIF, which Telegram plays like a GIF
+func (c *Converter) ConvertToMP4(videoPath, outputPath string, config *domain.Config) error {
+	filterChain, err := c.buildFilterChain(videoPath, config)
+	if err != nil {
+		return err
+	}
+
+	// yuv420p needs even dimensions
+	args := []string{
+		"-i", videoPath,
+		"-vf", filterChain + ",scale=trunc(iw/2)*2:trunc(ih/2)*2,format=yuv420p",
+		"-an",
+		"-c:v", "libx264",
+		"-movflags", "+faststart",
+		"-y", outputPath,
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to convert video to MP4: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertToWebP converts a video file to an endlessly looping animated WebP
+func (c *Converter) ConvertToWebP(videoPath, outputPath string, config *domain.Config) error {
+	filterChain, err := c.buildFilterChain(videoPath, config)
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"-i", videoPath,
+		"-vf", filterChain,
+		"-an",
+		"-c:v", "libwebp",
+		"-quality", strconv.Itoa(webpQuality),
+		"-loop", "0",
+		"-y", outputPath,
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to convert video to WebP: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertToSticker converts a video file to a VP9 WebM video sticker. The
+// longest side is scaled to the sticker size and the clip is cut to the
+// longest duration Telegram allows. Transparency is kept.
+func (c *Converter) ConvertToSticker(videoPath, outputPath string, config *domain.Config) error {
+	stickerConfig := *config
+	stickerConfig.GIF.Width = stickerSize
+	filterChain, err := c.buildFilterChain(videoPath, &stickerConfig)
+	if err != nil {
+		return err
+	}
+
+	scaleFilter := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", stickerSize, stickerSize)
+	args := []string{
+		"-i", videoPath,
+		"-vf", filterChain + "," + scaleFilter + ",format=yuva420p",
+		"-t", strconv.Itoa(stickerMaxDuration),
+		"-an",
+		"-c:v", "libvpx-vp9",
+		"-b:v", stickerBitrate,
+		"-y", outputPath,
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to convert video to sticker: %w", err)
+	}
+
+	return nil
+}
+
+// encodeGIF runs the palette and encoding passes for a filter chain
+func (c *Converter) encodeGIF(videoPath, outputPath, filterChain string, gif *domain.GIFConfig) error {
+	if gif.PaletteMode == domain.PaletteSingle {
+		return c.encodeGIFSinglePass(videoPath, outputPath, filterChain, gif)
 	}
 
 	// Add palette generation for better quality
 	palettePath := outputPath + ".palette.png"
-	paletteFilter := fmt.Sprintf("fps=%d,%s,palettegen=max_colors=%d",
-		config.GIF.FPS, scaleFilter, config.GIF.Colors)
+	paletteFilter := fmt.Sprintf("%s,%s", filterChain, paletteGenFilter(gif))
 
 	paletteArgs := []string{
 		"-i", videoPath,
@@ -65,13 +228,32 @@ func (c *Converter) ConvertToGIF(videoPath, outputPath string, config *domain.Co
 	}()
 
 	// Convert to GIF using palette
-	videoFilter := fmt.Sprintf("fps=%d,%s[x]", config.GIF.FPS, scaleFilter)
-	paletteUseFilter := "[x][1:v]paletteuse"
+	videoFilter := fmt.Sprintf("%s[x]", filterChain)
+	useFilter := "[x][1:v]" + paletteUseFilter(gif)
 
 	args := []string{
 		"-i", videoPath,
 		"-i", palettePath,
-		"-lavfi", fmt.Sprintf("%s;%s", videoFilter, paletteUseFilter),
+		"-lavfi", fmt.Sprintf("%s;%s", videoFilter, useFilter),
+		"-y", outputPath,
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to convert video to GIF: %w", err)
+	}
+
+	return nil
+}
+
+// encodeGIFSinglePass encodes a GIF with a palette for every frame. A
+// palette file keeps only one frame, so the palettes are streamed to
+// paletteuse in the same filter graph.
+func (c *Converter) encodeGIFSinglePass(videoPath, outputPath, filterChain string, gif *domain.GIFConfig) error {
+	args := []string{
+		"-i", videoPath,
+		"-lavfi", fmt.Sprintf("%s,split[a][b];[a]%s[p];[b][p]%s",
+			filterChain, paletteGenFilter(gif), paletteUseFilter(gif)),
 		"-y", outputPath,
 	}
 
@@ -83,9 +265,203 @@ func (c *Converter) ConvertToGIF(videoPath, outputPath string, config *domain.Co
 	return nil
 }
 
+// buildFilterChain builds the filter chain shared by the palette and GIF passes
+func (c *Converter) buildFilterChain(videoPath string, config *domain.Config) (string, error) {
+	var filters []string
+
+	// Cut out the requested part first so other filters only see it
+	if trim := trimFilter(config.GIF.TrimStart, config.GIF.TrimEnd); trim != "" {
+		filters = append(filters, trim)
+	}
+
+	// Change speed before sampling frames so fps applies to the result
+	if speed := config.GIF.PlaybackSpeed(); speed != 1 {
+		filters = append(filters, fmt.Sprintf("setpts=PTS/%s", strconv.FormatFloat(speed, 'f', -1, 64)))
+	}
+	filters = append(filters, fmt.Sprintf("fps=%d", config.GIF.FPS))
+
+	srcWidth, srcHeight, err := c.GetVideoSize(videoPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove black bars before scaling so they don't waste pixels and colors.
+	// Cropping is best effort, the video is converted as is if detection fails.
+	if config.GIF.AutoCrop {
+		if crop, _ := c.DetectCrop(videoPath); crop != nil {
+			filters = append(filters, crop.Filter())
+			srcWidth, srcHeight = crop.Width, crop.Height
+		}
+	}
+
+	// Scale to the width budget, reframing to another aspect ratio if asked
+	scaleFilter, width, height := scaleStage(srcWidth, srcHeight, config.GIF.Width, config.GIF.Reframe)
+	filters = append(filters, scaleFilter)
+
+	// Blend the end into the start when no seamless loop was found,
+	// a boomerang loops seamlessly anyway
+	if config.GIF.LoopCrossfade && !config.GIF.Boomerang {
+		duration, err := c.GetVideoDuration(videoPath)
+		if err != nil {
+			return "", err
+		}
+		start, end := domain.TimeRange{Start: config.GIF.TrimStart, End: config.GIF.TrimEnd}.Bounds(duration)
+		filters = append(filters, loopCrossfadeFilter((end-start)/config.GIF.PlaybackSpeed()))
+	}
+
+	// Timeline effects run on scaled frames, reverse buffers the whole clip
+	if config.GIF.Reverse {
+		filters = append(filters, "reverse")
+	}
+	if config.GIF.Boomerang {
+		filters = append(filters, boomerangFilter)
+	}
+
+	// Meme captions are sized for the scaled frame
+	if config.GIF.TopText != "" || config.GIF.BottomText != "" {
+		fontPath := config.GIF.FontPath
+		if fontPath == "" {
+			fontPath = defaultFontPath
+		}
+		filters = append(filters, textFilters(config.GIF.TopText, config.GIF.BottomText, fontPath, width, height)...)
+	}
+
+	// Cut out a circle with transparent corners (used for round videos)
+	if config.GIF.CircleMask {
+		filters = append(filters, circleMaskFilter)
+	}
+
+	return strings.Join(filters, ","), nil
+}
+
+// ConvertToVideoNote center-crops a video to a square of at most
+// maxVideoNoteSize pixels and encodes it for sendVideoNote.
+// It returns the side length of the resulting square.
+func (c *Converter) ConvertToVideoNote(videoPath, outputPath string) (int, error) {
+	width, height, err := c.GetVideoSize(videoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Video notes must be square with even dimensions for yuv420p
+	side := min(width, height, maxVideoNoteSize) &^ 1
+	if side <= 0 {
+		return 0, fmt.Errorf("invalid video size: %dx%d", width, height)
+	}
+
+	square := min(width, height)
+	videoFilter := fmt.Sprintf("crop=%d:%d,scale=%d:%d:flags=lanczos,setsar=1", square, square, side, side)
+
+	args := []string{
+		"-i", videoPath,
+		"-vf", videoFilter,
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-movflags", "+faststart",
+		"-y", outputPath,
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("failed to convert video to video note: %w", err)
+	}
+
+	return side, nil
+}
+
+// CreateSlideshow renders photos into a video, showing each photo for
+// frameDuration seconds with an optional crossfade between them.
+// All photos are fitted into the canvas of the first one.
+func (c *Converter) CreateSlideshow(photoPaths []string, outputPath string, frameDuration, crossfade float64) error {
+	if len(photoPaths) == 0 {
+		return fmt.Errorf("no photos for slideshow")
+	}
+
+	width, height, err := c.GetVideoSize(photoPaths[0])
+	if err != nil {
+		return err
+	}
+
+	// Limit the canvas size and keep dimensions even for yuv420p
+	if longest := max(width, height); longest > maxSlideshowSize {
+		width = width * maxSlideshowSize / longest
+		height = height * maxSlideshowSize / longest
+	}
+	width, height = max(width&^1, 2), max(height&^1, 2)
+
+	// A crossfade can't be longer than the photo it fades from
+	if crossfade >= frameDuration {
+		crossfade = frameDuration / 2
+	}
+
+	var args []string
+	var filters []string
+	for i, path := range photoPaths {
+		args = append(args, "-loop", "1", "-t", formatSeconds(frameDuration), "-i", path)
+		filters = append(filters, fmt.Sprintf(
+			"[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,"+
+				"setsar=1,fps=%d,format=yuv420p,settb=AVTB[v%d]",
+			i, width, height, width, height, slideshowFPS, i))
+	}
+
+	last := "v0"
+	if len(photoPaths) > 1 {
+		if crossfade > 0 {
+			for i := 1; i < len(photoPaths); i++ {
+				out := fmt.Sprintf("x%d", i)
+				offset := float64(i) * (frameDuration - crossfade)
+				filters = append(filters, fmt.Sprintf("[%s][v%d]xfade=transition=fade:duration=%s:offset=%s[%s]",
+					last, i, formatSeconds(crossfade), formatSeconds(offset), out))
+				last = out
+			}
+		} else {
+			var inputs strings.Builder
+			for i := range photoPaths {
+				fmt.Fprintf(&inputs, "[v%d]", i)
+			}
+			filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=0[out]", inputs.String(), len(photoPaths)))
+			last = "out"
+		}
+	}
+
+	args = append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "["+last+"]",
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-y", outputPath,
+	)
+
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to create slideshow: %w", err)
+	}
+
+	return nil
+}
+
+// trimFilter keeps the part of a video between start and end seconds,
+// an end of 0 keeps everything after start
+func trimFilter(start, end float64) string {
+	if start <= 0 && end <= 0 {
+		return ""
+	}
+
+	trim := "trim=start=" + formatSeconds(max(start, 0))
+	if end > 0 {
+		trim += ":end=" + formatSeconds(end)
+	}
+	return trim + ",setpts=PTS-STARTPTS"
+}
+
+// formatSeconds formats a duration in seconds for ffmpeg arguments
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
 // CheckFFmpeg checks if FFmpeg is available
 func CheckFFmpeg() error {
 	cmd := exec.Command("ffmpeg", "-version")
 	return cmd.Run()
 }
-
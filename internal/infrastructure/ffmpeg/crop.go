@@ -0,0 +1,106 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// cropSampleFPS is how many frames per second cropdetect looks at
+	cropSampleFPS = 2
+	// cropdetectLimit is the black level threshold for cropdetect
+	cropdetectLimit = 24
+	// minCropRatio ignores crops that remove less than this share of a side
+	minCropRatio = 0.02
+)
+
+// cropdetectPattern matches the suggestion cropdetect logs for every frame
+var cropdetectPattern = regexp.MustCompile(`crop=(-?\d+):(-?\d+):(-?\d+):(-?\d+)`)
+
+// CropRect is a rectangle of a video frame
+type CropRect struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Filter returns the crop filter for the rectangle
+func (r CropRect) Filter() string {
+	return fmt.Sprintf("crop=%d:%d:%d:%d", r.Width, r.Height, r.X, r.Y)
+}
+
+// DetectCrop runs cropdetect over sampled frames and returns a crop
+// rectangle that removes letterbox and pillarbox bars, or nil if the
+// video has no bars worth removing.
+func (c *Converter) DetectCrop(videoPath string) (*CropRect, error) {
+	width, height, err := c.GetVideoSize(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// reset=1 makes every suggestion independent of the previous frames
+	filter := fmt.Sprintf("fps=%d,cropdetect=limit=%d:round=2:reset=1", cropSampleFPS, cropdetectLimit)
+	cmd := exec.Command("ffmpeg", "-i", videoPath, "-vf", filter, "-an", "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to detect crop: %w", err)
+	}
+
+	rect, ok := stableCrop(cropdetectPattern.FindAllStringSubmatch(stderr.String(), -1))
+	if !ok {
+		return nil, nil
+	}
+
+	// Keep the rectangle inside the frame
+	rect.X, rect.Y = max(rect.X, 0), max(rect.Y, 0)
+	rect.Width, rect.Height = min(rect.Width, width-rect.X), min(rect.Height, height-rect.Y)
+
+	// Skip crops that would barely change anything
+	if float64(width-rect.Width) < float64(width)*minCropRatio &&
+		float64(height-rect.Height) < float64(height)*minCropRatio {
+		return nil, nil
+	}
+
+	return &rect, nil
+}
+
+// stableCrop combines per-frame cropdetect suggestions into one rectangle.
+// The union of all suggestions is used so that no frame loses content;
+// dark frames, which produce empty or tiny suggestions, don't shrink it.
+func stableCrop(matches [][]string) (CropRect, bool) {
+	found := false
+	var left, top, right, bottom int
+
+	for _, match := range matches {
+		var values [4]int
+		for i := range values {
+			values[i], _ = strconv.Atoi(match[i+1])
+		}
+		w, h, x, y := values[0], values[1], values[2], values[3]
+
+		// Fully black frames produce negative sizes
+		if w <= 0 || h <= 0 {
+			continue
+		}
+
+		if !found {
+			left, top, right, bottom = x, y, x+w, y+h
+			found = true
+			continue
+		}
+		left, top = min(left, x), min(top, y)
+		right, bottom = max(right, x+w), max(bottom, y+h)
+	}
+
+	if !found {
+		return CropRect{}, false
+	}
+
+	// Even sizes keep chroma subsampled formats happy
+	return CropRect{X: left, Y: top, Width: (right - left) &^ 1, Height: (bottom - top) &^ 1}, true
+}
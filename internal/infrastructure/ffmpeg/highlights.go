@@ -0,0 +1,130 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"gifmaker-bot/internal/domain"
+)
+
+const (
+	// highlightSampleFPS is how many frames per second are scored
+	highlightSampleFPS = 4
+	// highlightSampleWidth is the width frames are scored at
+	highlightSampleWidth = 160
+	// highlightSteps is how many window positions are tried per window length
+	highlightSteps = 4
+)
+
+// sceneScorePattern matches scene scores printed by the metadata filter
+var sceneScorePattern = regexp.MustCompile(`lavfi\.scene_score=([0-9.]+)`)
+
+// activitySample is the scene score of a frame at a point in time
+type activitySample struct {
+	time  float64
+	score float64
+}
+
+// FindHighlights scores windows of windowLength seconds between start and
+// end by motion and scene changes and returns up to count windows that
+// don't overlap, best first. A window covers the whole range if the range
+// is not longer than it.
+func (c *Converter) FindHighlights(videoPath string, start, end, windowLength float64, count int) ([]domain.TimeRange, error) {
+	if windowLength <= 0 || end-start <= windowLength {
+		return []domain.TimeRange{{Start: start, End: end}}, nil
+	}
+
+	samples, err := c.sceneActivity(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Slide the window in steps and score every position
+	type candidate struct {
+		window domain.TimeRange
+		score  float64
+	}
+	var candidates []candidate
+	step := windowLength / highlightSteps
+	last := end - windowLength
+	for position := start; ; position += step {
+		position = min(position, last)
+		window := domain.TimeRange{Start: position, End: position + windowLength}
+
+		score := 0.0
+		for _, sample := range samples {
+			if sample.time >= window.Start && sample.time < window.End {
+				score += sample.score
+			}
+		}
+		candidates = append(candidates, candidate{window: window, score: score})
+
+		if position >= last {
+			break
+		}
+	}
+
+	// The earliest window wins ties so still videos start at the beginning
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+
+	var highlights []domain.TimeRange
+	for _, candidate := range candidates {
+		if len(highlights) == count {
+			break
+		}
+		overlaps := slices.ContainsFunc(highlights, func(highlight domain.TimeRange) bool {
+			return candidate.window.Start < highlight.End && highlight.Start < candidate.window.End
+		})
+		if !overlaps {
+			highlights = append(highlights, candidate.window)
+		}
+	}
+
+	return highlights, nil
+}
+
+// sceneActivity returns the scene score of sampled frames. The score is the
+// difference to the previous frame, so it grows with motion and peaks at cuts.
+func (c *Converter) sceneActivity(videoPath string) ([]activitySample, error) {
+	filter := fmt.Sprintf("fps=%d,scale=%d:-2,select='gte(scene,0)',metadata=print",
+		highlightSampleFPS, highlightSampleWidth)
+	cmd := exec.Command("ffmpeg", "-i", videoPath, "-vf", filter, "-an", "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to score scenes: %w", err)
+	}
+
+	// metadata prints the frame time on one line and its scores below it
+	var samples []activitySample
+	frameTime := -1.0
+	scanner := bufio.NewScanner(&stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := ptsTimePattern.FindStringSubmatch(line); match != nil {
+			frameTime, _ = strconv.ParseFloat(match[1], 64)
+			continue
+		}
+		if match := sceneScorePattern.FindStringSubmatch(line); match != nil && frameTime >= 0 {
+			score, err := strconv.ParseFloat(match[1], 64)
+			if err == nil {
+				samples = append(samples, activitySample{time: frameTime, score: score})
+			}
+		}
+	}
+
+	return samples, nil
+}
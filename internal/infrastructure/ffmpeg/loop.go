@@ -0,0 +1,119 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+)
+
+const (
+	// loopSampleFPS is how many frames per second are compared
+	loopSampleFPS = 10
+	// loopMaxSamples bounds the frames compared pairwise, longer clips are
+	// sampled less often
+	loopMaxSamples = 300
+	// loopSampleSize is the side of the grayscale thumbnails compared
+	loopSampleSize = 32
+	// loopMinRatio is the shortest loop as a share of the clip length
+	loopMinRatio = 0.5
+	// loopMatchThreshold is the largest mean pixel difference (0-255)
+	// between the first and the last frame of a seamless loop
+	loopMatchThreshold = 6.0
+	// loopCrossfade is the crossfade length for clips without a seamless
+	// loop, in seconds of the result
+	loopCrossfade = 0.5
+)
+
+// FindLoop looks for the pair of frames between start and end that differ
+// the least and returns the range between them, so that the clip jumps from
+// its last frame to an almost identical first one. The loop is at least
+// half as long as the clip. It returns nil if no pair is similar enough.
+func (c *Converter) FindLoop(videoPath string, start, end float64) (*domain.TimeRange, error) {
+	// Every pair of frames is compared, so the sample count is bounded
+	rate := float64(loopSampleFPS)
+	if duration := end - start; duration > 0 {
+		rate = min(rate, loopMaxSamples/duration)
+	}
+
+	frames, err := c.sampleGrayFrames(videoPath, start, end, rate)
+	if err != nil {
+		return nil, err
+	}
+
+	minFrames := max(int(float64(len(frames))*loopMinRatio), 1)
+	bestDiff := loopMatchThreshold
+	bestFirst, bestLast := -1, -1
+	for first := 0; first+minFrames < len(frames); first++ {
+		for last := first + minFrames; last < len(frames); last++ {
+			if diff := frameDifference(frames[first], frames[last], bestDiff); diff < bestDiff {
+				bestDiff, bestFirst, bestLast = diff, first, last
+			}
+		}
+	}
+	if bestFirst < 0 {
+		return nil, nil
+	}
+
+	// The matching last frame is left out, the first one takes its place
+	return &domain.TimeRange{
+		Start: start + float64(bestFirst)/rate,
+		End:   start + float64(bestLast)/rate,
+	}, nil
+}
+
+// sampleGrayFrames decodes small grayscale frames between start and end,
+// rate frames per second
+func (c *Converter) sampleGrayFrames(videoPath string, start, end, rate float64) ([][]byte, error) {
+	filter := fmt.Sprintf("%s,fps=%s,scale=%d:%d,format=gray",
+		trimFilter(start, end), strconv.FormatFloat(rate, 'f', -1, 64), loopSampleSize, loopSampleSize)
+	cmd := exec.Command("ffmpeg", "-i", videoPath, "-vf", strings.TrimPrefix(filter, ","),
+		"-an", "-f", "rawvideo", "-")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to sample frames: %w", err)
+	}
+
+	const frameSize = loopSampleSize * loopSampleSize
+	data := stdout.Bytes()
+	frames := make([][]byte, 0, len(data)/frameSize)
+	for len(data) >= frameSize {
+		frames = append(frames, data[:frameSize])
+		data = data[frameSize:]
+	}
+	return frames, nil
+}
+
+// frameDifference returns the mean absolute pixel difference of two frames.
+// It stops early and returns limit once the difference can't get below it.
+func frameDifference(a, b []byte, limit float64) float64 {
+	budget := int(limit * float64(len(a)))
+	total := 0
+	for i := range a {
+		if a[i] > b[i] {
+			total += int(a[i] - b[i])
+		} else {
+			total += int(b[i] - a[i])
+		}
+		if total >= budget {
+			return limit
+		}
+	}
+	return float64(total) / float64(len(a))
+}
+
+// loopCrossfadeFilter blends the last moments of a clip of the given
+// length into its first ones, so the end flows into the start. The result
+// is one crossfade shorter and ends where its start continues.
+func loopCrossfadeFilter(length float64) string {
+	fade := min(loopCrossfade, length/4)
+	return fmt.Sprintf("split[loopbody][loophead];"+
+		"[loophead]trim=end=%[1]s,setpts=PTS-STARTPTS[head];"+
+		"[loopbody]trim=start=%[1]s,setpts=PTS-STARTPTS[body];"+
+		"[body][head]xfade=transition=fade:duration=%[1]s:offset=%[2]s",
+		formatSeconds(fade), formatSeconds(length-2*fade))
+}
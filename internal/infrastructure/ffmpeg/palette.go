@@ -0,0 +1,46 @@
+package ffmpeg
+
+import (
+	"fmt"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// paletteGenFilter returns the palettegen filter for the palette mode
+func paletteGenFilter(gif *domain.GIFConfig) string {
+	filter := fmt.Sprintf("palettegen=max_colors=%d", gif.Colors)
+
+	switch gif.PaletteMode {
+	case domain.PaletteDiff:
+		filter += ":stats_mode=diff"
+	case domain.PaletteSingle:
+		filter += ":stats_mode=single"
+	}
+
+	return filter
+}
+
+// paletteUseFilter returns the paletteuse filter for the dithering and
+// palette modes
+func paletteUseFilter(gif *domain.GIFConfig) string {
+	filter := "paletteuse=alpha_threshold=128"
+
+	if gif.Dither.IsValid() {
+		filter += ":dither=" + string(gif.Dither)
+		if gif.Dither == domain.DitherBayer {
+			scale := min(max(gif.BayerScale, domain.MinBayerScale), domain.MaxBayerScale)
+			filter += fmt.Sprintf(":bayer_scale=%d", scale)
+		}
+	}
+
+	switch gif.PaletteMode {
+	case domain.PaletteDiff:
+		// Only redraw the changed rectangle, it matches the diff statistics
+		filter += ":diff_mode=rectangle"
+	case domain.PaletteSingle:
+		// Use the new palette coming with every frame
+		filter += ":new=1"
+	}
+
+	return filter
+}
@@ -0,0 +1,65 @@
+package ffmpeg
+
+import (
+	"fmt"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// scaleStage builds the filters that scale a srcWidth x srcHeight frame into
+// the width budget and returns them with the resulting frame size.
+// Reframed outputs are as wide as the budget, their height follows from
+// the target aspect.
+func scaleStage(srcWidth, srcHeight, budget int, mode domain.ReframeMode) (string, int, int) {
+	aspectW, aspectH, ok := mode.Aspect()
+	if !ok || srcWidth <= 0 || srcHeight <= 0 {
+		return plainScale(srcWidth, srcHeight, budget)
+	}
+
+	if mode.IsBlurPad() {
+		width, height := fitAspect(aspectW, aspectH, budgetOr(budget, srcWidth))
+		return blurPadFilter(width, height), width, height
+	}
+
+	// Center crop to the target aspect, then scale without upscaling
+	cropWidth, cropHeight := srcWidth, srcWidth*aspectH/aspectW
+	if cropHeight > srcHeight {
+		cropWidth, cropHeight = srcHeight*aspectW/aspectH, srcHeight
+	}
+	width, height := fitAspect(aspectW, aspectH, min(budgetOr(budget, cropWidth), cropWidth))
+
+	filter := fmt.Sprintf("crop=%d:%d,scale=%d:%d:flags=lanczos,setsar=1", cropWidth, cropHeight, width, height)
+	return filter, width, height
+}
+
+// plainScale scales a frame to the budget width keeping its aspect ratio
+func plainScale(srcWidth, srcHeight, budget int) (string, int, int) {
+	if budget <= 0 || srcWidth <= 0 {
+		return "scale=-1:-1:flags=lanczos", srcWidth, srcHeight
+	}
+	return fmt.Sprintf("scale=%d:-1:flags=lanczos", budget), budget, srcHeight * budget / srcWidth
+}
+
+// blurPadFilter fits the frame into width x height over a blurred copy of
+// itself that fills the whole canvas
+func blurPadFilter(width, height int) string {
+	blur := max(max(width, height)/40, 2)
+	return fmt.Sprintf("split[bgsrc][fgsrc];"+
+		"[bgsrc]scale=%[1]d:%[2]d:force_original_aspect_ratio=increase,crop=%[1]d:%[2]d,boxblur=%[3]d:2[bg];"+
+		"[fgsrc]scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease:flags=lanczos[fg];"+
+		"[bg][fg]overlay=(W-w)/2:(H-h)/2,setsar=1",
+		width, height, blur)
+}
+
+// fitAspect returns the even frame size with the given aspect ratio and width
+func fitAspect(aspectW, aspectH, width int) (int, int) {
+	return max(width&^1, 2), max((width*aspectH/aspectW)&^1, 2)
+}
+
+// budgetOr returns the budget or a fallback when the budget is automatic
+func budgetOr(budget, fallback int) int {
+	if budget > 0 {
+		return budget
+	}
+	return fallback
+}
@@ -0,0 +1,244 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+	"gifmaker-bot/internal/infrastructure/gifutil"
+)
+
+const (
+	// minSceneLength merges cuts that are closer than this many seconds
+	minSceneLength = 0.5
+	// maxScenes limits how many separately encoded parts a GIF has
+	maxScenes = 16
+	// maxSceneClipDuration is the longest playback in seconds that is
+	// encoded scene by scene, longer GIFs use one palette
+	maxSceneClipDuration = 30
+	// minSSIMGain is how much better the bigger of the two encodings has
+	// to look to be kept
+	minSSIMGain = 0.005
+)
+
+var (
+	// ptsTimePattern matches frame timestamps logged by showinfo
+	ptsTimePattern = regexp.MustCompile(`pts_time:\s*(-?[0-9.]+)`)
+	// ptsPattern matches frame timestamps logged by showinfo, which are
+	// frame indexes after setpts=N
+	ptsPattern = regexp.MustCompile(`\spts:\s*([0-9]+)`)
+	// ssimPattern matches the summary logged by the ssim filter
+	ssimPattern = regexp.MustCompile(`All:([0-9.]+)`)
+)
+
+// convertScenes encodes every scene with its own palette and joins the
+// parts into one GIF. The result is compared with a single palette
+// encoding and the smaller GIF is kept unless the other one looks
+// noticeably better.
+func (c *Converter) convertScenes(videoPath, outputPath, filterChain string, gif *domain.GIFConfig) error {
+	// Every part and the comparison GIF use one palette each
+	partGIF := *gif
+	partGIF.PaletteMode = domain.PaletteGlobal
+
+	// Encoding every scene and comparing the results takes several passes,
+	// long clips aren't worth it
+	duration, err := c.GetVideoDuration(videoPath)
+	if err != nil {
+		return err
+	}
+	trimStart, trimEnd := domain.TimeRange{Start: gif.TrimStart, End: gif.TrimEnd}.Bounds(duration)
+	if gif.OutputDuration(trimEnd-trimStart) > maxSceneClipDuration {
+		return c.encodeGIF(videoPath, outputPath, filterChain, &partGIF)
+	}
+
+	threshold := gif.SceneThreshold
+	if threshold <= 0 {
+		threshold = domain.DefaultSceneThreshold
+	}
+
+	cuts, err := c.detectSceneCuts(videoPath, filterChain, threshold, gif.FPS)
+	if err != nil {
+		return err
+	}
+	if len(cuts) == 0 {
+		return c.encodeGIF(videoPath, outputPath, filterChain, &partGIF)
+	}
+
+	partPaths := make([]string, 0, len(cuts)+1)
+	for i := 0; i <= len(cuts); i++ {
+		partPaths = append(partPaths, fmt.Sprintf("%s.scene%02d.gif", outputPath, i))
+	}
+	defer func() {
+		for _, path := range partPaths {
+			_ = os.Remove(path)
+		}
+	}()
+
+	if err := c.encodeScenes(videoPath, filterChain, cuts, partPaths, &partGIF); err != nil {
+		return err
+	}
+
+	scenePath := outputPath + ".scenes.gif"
+	singlePath := outputPath + ".single.gif"
+	defer func() {
+		_ = os.Remove(scenePath)
+		_ = os.Remove(singlePath)
+	}()
+
+	if err := gifutil.Join(partPaths, scenePath); err != nil {
+		return fmt.Errorf("failed to join scenes: %w", err)
+	}
+
+	if err := c.encodeGIF(videoPath, singlePath, filterChain, &partGIF); err != nil {
+		return err
+	}
+
+	chosen := c.compareEncodings(videoPath, filterChain, scenePath, singlePath, len(partPaths))
+	if err := os.Rename(chosen, outputPath); err != nil {
+		return fmt.Errorf("failed to move GIF: %w", err)
+	}
+
+	return nil
+}
+
+// compareEncodings logs size and SSIM of the scene and single palette GIFs
+// and returns the path of the one to keep: the one that fits the size limit,
+// otherwise the smaller one unless the bigger one is noticeably better
+func (c *Converter) compareEncodings(videoPath, filterChain, scenePath, singlePath string, scenes int) string {
+	sceneSize, singleSize := fileSize(scenePath), fileSize(singlePath)
+
+	smaller, bigger := singlePath, scenePath
+	if sceneSize < singleSize {
+		smaller, bigger = scenePath, singlePath
+	}
+	if max(sceneSize, singleSize) > domain.MaxOutputSize {
+		log.Printf("Scene palettes: %d scenes, %d bytes vs single palette %d bytes (over the size limit)",
+			scenes, sceneSize, singleSize)
+		return smaller
+	}
+
+	sceneSSIM, sceneErr := c.ssim(videoPath, scenePath, filterChain)
+	singleSSIM, singleErr := c.ssim(videoPath, singlePath, filterChain)
+
+	if sceneErr != nil || singleErr != nil {
+		log.Printf("Scene palettes: %d scenes, %d bytes vs single palette %d bytes (SSIM unavailable)",
+			scenes, sceneSize, singleSize)
+		return smaller
+	}
+
+	log.Printf("Scene palettes: %d scenes, %d bytes, SSIM %.4f vs single palette %d bytes, SSIM %.4f",
+		scenes, sceneSize, sceneSSIM, singleSize, singleSSIM)
+
+	smallerSSIM, biggerSSIM := singleSSIM, sceneSSIM
+	if smaller == scenePath {
+		smallerSSIM, biggerSSIM = sceneSSIM, singleSSIM
+	}
+	if biggerSSIM-smallerSSIM > minSSIMGain {
+		return bigger
+	}
+	return smaller
+}
+
+// encodeScenes decodes the video once and encodes every part between two
+// cuts with its own palette. Cuts are frame indexes of the filtered
+// stream, so trimming after the same filter chain is frame exact.
+func (c *Converter) encodeScenes(videoPath, filterChain string, cuts []int, partPaths []string, gif *domain.GIFConfig) error {
+	graph := []string{fmt.Sprintf("%s,split=%d%s", filterChain, len(partPaths), sceneLabels("s", len(partPaths)))}
+	var outputs []string
+
+	start := 0
+	for i := range partPaths {
+		trim := "trim=start_frame=" + strconv.Itoa(start)
+		if i < len(cuts) {
+			trim += ":end_frame=" + strconv.Itoa(cuts[i])
+			start = cuts[i]
+		}
+
+		graph = append(graph, fmt.Sprintf("[s%d]%s,setpts=PTS-STARTPTS,split[a%d][b%d];[a%d]%s[p%d];[b%d][p%d]%s[o%d]",
+			i, trim, i, i, i, paletteGenFilter(gif), i, i, i, paletteUseFilter(gif), i))
+		outputs = append(outputs, "-map", fmt.Sprintf("[o%d]", i), "-y", partPaths[i])
+	}
+
+	args := append([]string{"-i", videoPath, "-filter_complex", strings.Join(graph, ";")}, outputs...)
+	cmd := exec.Command("ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to encode scenes: %w", err)
+	}
+
+	return nil
+}
+
+// sceneLabels returns the output labels of a split into count streams
+func sceneLabels(prefix string, count int) string {
+	var labels strings.Builder
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&labels, "[%s%d]", prefix, i)
+	}
+	return labels.String()
+}
+
+// detectSceneCuts returns frame indexes of the filtered stream where a new
+// scene starts
+func (c *Converter) detectSceneCuts(videoPath, filterChain string, threshold float64, fps int) ([]int, error) {
+	// Numbering frames before select keeps the index of the filtered stream,
+	// showinfo's own counter only counts selected frames
+	filter := fmt.Sprintf("%s,setpts=N,select='gt(scene,%s)',showinfo", filterChain,
+		strconv.FormatFloat(threshold, 'f', -1, 64))
+
+	cmd := exec.Command("ffmpeg", "-i", videoPath, "-vf", filter, "-an", "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to detect scenes: %w", err)
+	}
+
+	minFrames := int(math.Ceil(minSceneLength * float64(fps)))
+	var cuts []int
+	last := 0
+	for _, match := range ptsPattern.FindAllStringSubmatch(stderr.String(), -1) {
+		frame, err := strconv.Atoi(match[1])
+		if err != nil || frame-last < minFrames {
+			continue
+		}
+		cuts = append(cuts, frame)
+		last = frame
+		if len(cuts) == maxScenes-1 {
+			break
+		}
+	}
+
+	return cuts, nil
+}
+
+// ssim returns the average SSIM of a GIF against the filtered source
+func (c *Converter) ssim(videoPath, gifPath, filterChain string) (float64, error) {
+	graph := fmt.Sprintf("%s[ref];[1:v][ref]ssim", filterChain)
+	cmd := exec.Command("ffmpeg", "-i", videoPath, "-i", gifPath, "-lavfi", graph, "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("failed to compute SSIM: %w", err)
+	}
+
+	matches := ssimPattern.FindAllStringSubmatch(stderr.String(), -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no SSIM in ffmpeg output")
+	}
+	return strconv.ParseFloat(matches[len(matches)-1][1], 64)
+}
+
+// fileSize returns the size of a file or 0 if it can't be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
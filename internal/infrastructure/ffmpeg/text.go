@@ -0,0 +1,129 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultFontPath is the bundled font used for captions. DejaVu Sans Bold
+// covers Latin and Cyrillic and looks close enough to Impact.
+const defaultFontPath = "fonts/DejaVuSans-Bold.ttf"
+
+const (
+	// glyphWidthRatio is the average advance of an uppercase glyph relative
+	// to the font size, used to estimate the rendered text width
+	glyphWidthRatio = 0.7
+	// lineHeightRatio is the line height relative to the font size
+	lineHeightRatio = 1.15
+	// minFontSize is the smallest font size before text is wrapped
+	minFontSize = 14
+	// maxTextLines limits how many lines a caption is wrapped into
+	maxTextLines = 3
+)
+
+// textFilters returns drawtext filters for Impact-style top and bottom
+// captions on a frame of the given size
+func textFilters(top, bottom, fontPath string, width, height int) []string {
+	var filters []string
+	margin := height / 30
+
+	if top = strings.TrimSpace(top); top != "" {
+		lines, size := fitText(strings.ToUpper(top), width, height)
+		lineHeight := int(float64(size) * lineHeightRatio)
+		for i, line := range lines {
+			y := margin + i*lineHeight
+			filters = append(filters, drawTextFilter(line, fontPath, size, y))
+		}
+	}
+
+	if bottom = strings.TrimSpace(bottom); bottom != "" {
+		lines, size := fitText(strings.ToUpper(bottom), width, height)
+		lineHeight := int(float64(size) * lineHeightRatio)
+		for i, line := range lines {
+			y := height - margin - (len(lines)-i)*lineHeight
+			filters = append(filters, drawTextFilter(line, fontPath, size, y))
+		}
+	}
+
+	return filters
+}
+
+// drawTextFilter renders one horizontally centered line of text
+func drawTextFilter(text, fontPath string, size, y int) string {
+	border := max(size/14, 2)
+	return fmt.Sprintf("drawtext=fontfile=%s:text=%s:expansion=none:fontsize=%d:"+
+		"fontcolor=white:borderw=%d:bordercolor=black:x=(w-text_w)/2:y=%d",
+		escapeFilterValue(fontPath), escapeFilterValue(text), size, border, y)
+}
+
+// fitText picks a font size that fits the text into the frame width,
+// wrapping it into several lines when a single line would be too small
+func fitText(text string, width, height int) ([]string, int) {
+	maxSize := max(height/8, minFontSize)
+	words := strings.Fields(text)
+
+	var lines []string
+	var size int
+	for n := 1; n <= maxTextLines; n++ {
+		lines = wrapWords(words, n)
+		size = min(maxSize, fontSizeForWidth(longestLine(lines), width))
+		if size >= minFontSize || n >= len(words) {
+			break
+		}
+	}
+
+	return lines, max(size, minFontSize/2)
+}
+
+// fontSizeForWidth estimates the largest font size at which a line of
+// runes characters fits into 90% of the frame width
+func fontSizeForWidth(runes, width int) int {
+	if runes == 0 {
+		return width
+	}
+	return int(float64(width) * 0.9 / (float64(runes) * glyphWidthRatio))
+}
+
+// wrapWords splits words into at most n lines of similar length
+func wrapWords(words []string, n int) []string {
+	if n <= 1 || len(words) <= 1 {
+		return []string{strings.Join(words, " ")}
+	}
+
+	total := 0
+	for _, w := range words {
+		total += utf8.RuneCountInString(w) + 1
+	}
+	target := total / n
+
+	var lines []string
+	var current []string
+	length := 0
+	for _, w := range words {
+		if length > 0 && length+utf8.RuneCountInString(w) > target && len(lines) < n-1 {
+			lines = append(lines, strings.Join(current, " "))
+			current, length = nil, 0
+		}
+		current = append(current, w)
+		length += utf8.RuneCountInString(w) + 1
+	}
+	return append(lines, strings.Join(current, " "))
+}
+
+// longestLine returns the length in runes of the longest line
+func longestLine(lines []string) int {
+	longest := 0
+	for _, line := range lines {
+		longest = max(longest, utf8.RuneCountInString(line))
+	}
+	return longest
+}
+
+// escapeFilterValue escapes a string for use as a filter option value
+// inside a filtergraph. The value is escaped twice: first for the option
+// parser and then for the filtergraph parser that sees it before.
+func escapeFilterValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
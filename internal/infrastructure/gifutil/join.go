@@ -0,0 +1,74 @@
+package gifutil
+
+import (
+	"fmt"
+	"image/gif"
+	"os"
+)
+
+// Join concatenates GIF files frame by frame into one GIF. Frames are
+// copied as they are, so every part keeps its own palette and timing.
+func Join(partPaths []string, outputPath string) error {
+	var joined *gif.GIF
+	for _, path := range partPaths {
+		part, err := decodeFile(path)
+		if err != nil {
+			return err
+		}
+
+		if joined == nil {
+			joined = part
+			continue
+		}
+
+		if part.Config.Width != joined.Config.Width || part.Config.Height != joined.Config.Height {
+			return fmt.Errorf("GIF parts have different sizes: %dx%d and %dx%d",
+				joined.Config.Width, joined.Config.Height, part.Config.Width, part.Config.Height)
+		}
+
+		// Clear the last frame of the previous part so transparent pixels
+		// of the next part don't show it
+		if last := len(joined.Disposal) - 1; last >= 0 {
+			joined.Disposal[last] = gif.DisposalBackground
+		}
+
+		joined.Image = append(joined.Image, part.Image...)
+		joined.Delay = append(joined.Delay, part.Delay...)
+		joined.Disposal = append(joined.Disposal, part.Disposal...)
+	}
+
+	if joined == nil {
+		return fmt.Errorf("no GIF parts to join")
+	}
+
+	return encodeFile(outputPath, joined)
+}
+
+// decodeFile reads all frames of a GIF file
+func decodeFile(path string) (*gif.GIF, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GIF: %w", err)
+	}
+	defer file.Close()
+
+	g, err := gif.DecodeAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+	return g, nil
+}
+
+// encodeFile writes all frames of a GIF to a file
+func encodeFile(path string, g *gif.GIF) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create GIF: %w", err)
+	}
+	defer file.Close()
+
+	if err := gif.EncodeAll(file, g); err != nil {
+		return fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package gifutil
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	dir := t.TempDir()
+	parts := [][]testFrame{
+		{
+			{rect: full(8), fill: black},
+			{rect: square(2, 2), fill: red},
+		},
+		{
+			{rect: full(8), fill: white, patches: []patch{{square(0, 0), green}}},
+			// Transparent pixels show the first frame of this part only
+			{rect: full(8), fill: clear, patches: []patch{{square(4, 4), gray}}},
+		},
+		{
+			{rect: full(8), fill: gray},
+		},
+	}
+
+	var partPaths []string
+	var want []*image.RGBA
+	for i, frames := range parts {
+		part := buildGIF(8, testPalette, frames...)
+		path := filepath.Join(dir, fmt.Sprintf("part%d.gif", i))
+		if err := encodeFile(path, part); err != nil {
+			t.Fatal(err)
+		}
+		partPaths = append(partPaths, path)
+		want = append(want, composite(roundTrip(t, part))...)
+	}
+
+	outputPath := filepath.Join(dir, "joined.gif")
+	if err := Join(partPaths, outputPath); err != nil {
+		t.Fatalf("Join error: %v", err)
+	}
+	joined, err := decodeFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := composite(joined)
+	if len(got) != len(want) {
+		t.Fatalf("joined GIF has %d frames, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if x, y, diff := maxDiff(want[i], got[i]); diff > 0 {
+			t.Errorf("frame %d: pixel (%d, %d) is %v, want %v", i, x, y, got[i].RGBAAt(x, y), want[i].RGBAAt(x, y))
+		}
+	}
+	if total(joined.Delay) != 10*len(want) {
+		t.Errorf("joined GIF plays for %dcs, want %dcs", total(joined.Delay), 10*len(want))
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.gif")
+	big := filepath.Join(dir, "big.gif")
+	if err := encodeFile(small, buildGIF(8, testPalette, testFrame{rect: full(8), fill: black})); err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeFile(big, buildGIF(16, testPalette, testFrame{rect: full(16), fill: black})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		parts []string
+	}{
+		{"no parts", nil},
+		{"different sizes", []string{small, big}},
+		{"missing part", []string{small, filepath.Join(dir, "missing.gif")}},
+	}
+
+	for _, tt := range tests {
+		if err := Join(tt.parts, filepath.Join(dir, "joined.gif")); err == nil {
+			t.Errorf("%s: Join succeeded", tt.name)
+		}
+	}
+}
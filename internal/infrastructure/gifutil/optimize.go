@@ -0,0 +1,224 @@
+package gifutil
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+)
+
+// OptimizeResult describes what the optimizer changed
+type OptimizeResult struct {
+	OriginalSize  int64
+	OptimizedSize int64
+	DroppedFrames int
+}
+
+// Saved returns how many bytes the optimizer saved
+func (r OptimizeResult) Saved() int64 {
+	return r.OriginalSize - r.OptimizedSize
+}
+
+// Optimize rewrites a GIF file so that every frame only stores the
+// rectangle that changed since the previous frame, with unchanged pixels
+// inside it made transparent. Frames without changes are dropped and their
+// delay is added to the previous frame.
+//
+// With lossy > 0, pixels whose channels differ from what is already shown
+// by at most lossy are treated as unchanged. This leaves longer transparent
+// runs that LZW compresses well, at the cost of a bounded color error.
+//
+// The file is only replaced if the result is smaller.
+func Optimize(path string, lossy int) (OptimizeResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return OptimizeResult{}, fmt.Errorf("failed to stat GIF: %w", err)
+	}
+	result := OptimizeResult{OriginalSize: info.Size(), OptimizedSize: info.Size()}
+
+	src, err := decodeFile(path)
+	if err != nil {
+		return result, err
+	}
+
+	optimized, dropped, ok := optimizeFrames(src, lossy)
+	if !ok {
+		return result, nil
+	}
+
+	tempPath := path + ".optimized"
+	defer func() {
+		_ = os.Remove(tempPath)
+	}()
+	if err := encodeFile(tempPath, optimized); err != nil {
+		return result, err
+	}
+
+	info, err = os.Stat(tempPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to stat optimized GIF: %w", err)
+	}
+	if info.Size() >= result.OriginalSize {
+		return result, nil
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return result, fmt.Errorf("failed to replace GIF: %w", err)
+	}
+
+	result.OptimizedSize = info.Size()
+	result.DroppedFrames = dropped
+	return result, nil
+}
+
+// optimizeFrames re-encodes frames as changed rectangles over what the
+// previous frames left on screen. It reports false if the GIF can't be
+// optimized this way, which happens when pixels turn transparent.
+func optimizeFrames(src *gif.GIF, lossy int) (*gif.GIF, int, bool) {
+	bounds := image.Rect(0, 0, src.Config.Width, src.Config.Height)
+	canvas := image.NewRGBA(bounds) // what the source GIF shows
+	shown := image.NewRGBA(bounds)  // what the optimized GIF shows
+
+	out := &gif.GIF{
+		LoopCount:       src.LoopCount,
+		Config:          src.Config,
+		BackgroundIndex: src.BackgroundIndex,
+	}
+	dropped := 0
+
+	for i, frame := range src.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(src.Disposal) {
+			disposal = src.Disposal[i]
+		}
+
+		var saved *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			saved = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		rect, ok := changedRect(canvas, shown, lossy)
+		if !ok {
+			return nil, 0, false
+		}
+
+		if rect.Empty() && len(out.Image) > 0 {
+			// Nothing visible changed, show the previous frame longer
+			out.Delay[len(out.Delay)-1] += src.Delay[i]
+			dropped++
+		} else {
+			if rect.Empty() {
+				rect = image.Rect(0, 0, 1, 1)
+			}
+			out.Image = append(out.Image, encodeRect(canvas, shown, rect, frame.Palette, lossy))
+			out.Delay = append(out.Delay, src.Delay[i])
+			out.Disposal = append(out.Disposal, gif.DisposalNone)
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+
+	// Frames sharing the first palette don't need local color tables
+	if len(out.Image) > 0 {
+		out.Config.ColorModel = out.Image[0].Palette
+	}
+
+	return out, dropped, true
+}
+
+// changedRect returns the bounding box of pixels that differ between the
+// source canvas and what is shown by more than lossy. It reports false if
+// a shown pixel would have to become transparent.
+func changedRect(canvas, shown *image.RGBA, lossy int) (image.Rectangle, bool) {
+	var rect image.Rectangle
+	bounds := canvas.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			want, have := canvas.RGBAAt(x, y), shown.RGBAAt(x, y)
+			if similar(want, have, lossy) {
+				continue
+			}
+			if want.A == 0 {
+				return image.Rectangle{}, false
+			}
+			rect = rect.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return rect, true
+}
+
+// encodeRect encodes the rectangle of the canvas with the frame palette.
+// Unchanged pixels become transparent when the palette has room for a
+// transparent color. The shown image is updated with the written pixels.
+func encodeRect(canvas, shown *image.RGBA, rect image.Rectangle, palette color.Palette, lossy int) *image.Paletted {
+	palette, transparent := withTransparent(palette)
+	indexes := make(map[color.RGBA]uint8, len(palette))
+	for i, c := range palette {
+		indexes[color.RGBAModel.Convert(c).(color.RGBA)] = uint8(i)
+	}
+
+	img := image.NewPaletted(rect, palette)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			want, have := canvas.RGBAAt(x, y), shown.RGBAAt(x, y)
+			if transparent >= 0 && similar(want, have, lossy) {
+				img.SetColorIndex(x, y, uint8(transparent))
+				continue
+			}
+
+			index, ok := indexes[want]
+			if !ok {
+				index = uint8(palette.Index(want))
+			}
+			img.SetColorIndex(x, y, index)
+			shown.SetRGBA(x, y, color.RGBAModel.Convert(palette[index]).(color.RGBA))
+		}
+	}
+	return img
+}
+
+// withTransparent returns the palette with a transparent color and its
+// index, adding the color if there is room. The index is -1 if the
+// palette is full and has no transparent color.
+func withTransparent(palette color.Palette) (color.Palette, int) {
+	for i, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return palette, i
+		}
+	}
+	if len(palette) < 256 {
+		palette = append(palette[:len(palette):len(palette)], color.RGBA{})
+		return palette, len(palette) - 1
+	}
+	return palette, -1
+}
+
+// similar reports whether no channel of two colors differs by more than lossy
+func similar(a, b color.RGBA, lossy int) bool {
+	return absDiff(a.R, b.R) <= lossy && absDiff(a.G, b.G) <= lossy &&
+		absDiff(a.B, b.B) <= lossy && absDiff(a.A, b.A) <= lossy
+}
+
+// absDiff returns the absolute difference of two channel values
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// cloneRGBA returns a copy of an image
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
@@ -0,0 +1,318 @@
+package gifutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	black = iota
+	white
+	red
+	green
+	gray
+	lightGray // differs from gray by 2 in every channel
+	clear
+)
+
+var testPalette = color.Palette{
+	black:     color.RGBA{0, 0, 0, 255},
+	white:     color.RGBA{255, 255, 255, 255},
+	red:       color.RGBA{255, 0, 0, 255},
+	green:     color.RGBA{0, 255, 0, 255},
+	gray:      color.RGBA{100, 100, 100, 255},
+	lightGray: color.RGBA{102, 102, 102, 255},
+	clear:     color.RGBA{},
+}
+
+// testFrame is a frame of a test GIF: a rectangle filled with a color and
+// optional patches painted over it
+type testFrame struct {
+	rect     image.Rectangle
+	fill     uint8
+	patches  []patch
+	disposal byte
+}
+
+type patch struct {
+	rect  image.Rectangle
+	index uint8
+}
+
+// full returns the rectangle of a whole test GIF
+func full(size int) image.Rectangle {
+	return image.Rect(0, 0, size, size)
+}
+
+// square returns a rectangle of side 2 at x, y
+func square(x, y int) image.Rectangle {
+	return image.Rect(x, y, x+2, y+2)
+}
+
+// buildGIF builds a GIF of size x size pixels, every frame shown for 10cs
+func buildGIF(size int, palette color.Palette, frames ...testFrame) *gif.GIF {
+	g := &gif.GIF{Config: image.Config{Width: size, Height: size, ColorModel: palette}}
+	for _, f := range frames {
+		img := image.NewPaletted(f.rect, palette)
+		draw.Draw(img, f.rect, image.NewUniform(palette[f.fill]), image.Point{}, draw.Src)
+		for _, p := range f.patches {
+			draw.Draw(img, p.rect.Intersect(f.rect), image.NewUniform(palette[p.index]), image.Point{}, draw.Src)
+		}
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 10)
+		g.Disposal = append(g.Disposal, f.disposal)
+	}
+	return g
+}
+
+// roundTrip encodes and decodes a GIF, as it would be read from a file
+func roundTrip(t *testing.T, g *gif.GIF) *gif.GIF {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("failed to encode GIF: %v", err)
+	}
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode GIF: %v", err)
+	}
+	return decoded
+}
+
+// composite returns what a viewer shows while every frame is displayed
+func composite(g *gif.GIF) []*image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	shown := make([]*image.RGBA, 0, len(g.Image))
+	for i, frame := range g.Image {
+		var saved *image.RGBA
+		if g.Disposal[i] == gif.DisposalPrevious {
+			saved = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		shown = append(shown, cloneRGBA(canvas))
+
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+	return shown
+}
+
+// compareShown fails the test if, at the start of any frame of want, got
+// shows an image that differs by more than tolerance in some channel
+func compareShown(t *testing.T, want, got *gif.GIF, tolerance int) {
+	t.Helper()
+	if total(want.Delay) != total(got.Delay) {
+		t.Fatalf("GIF plays for %dcs, want %dcs", total(got.Delay), total(want.Delay))
+	}
+
+	wantShown, gotShown := composite(want), composite(got)
+	j, gotEnd, start := 0, got.Delay[0], 0
+	for i, wantImg := range wantShown {
+		for start >= gotEnd {
+			j++
+			gotEnd += got.Delay[j]
+		}
+		if x, y, diff := maxDiff(wantImg, gotShown[j]); diff > tolerance {
+			t.Errorf("frame %d at %dcs: pixel (%d, %d) is %v, want %v",
+				i, start, x, y, gotShown[j].RGBAAt(x, y), wantImg.RGBAAt(x, y))
+		}
+		start += want.Delay[i]
+	}
+}
+
+// maxDiff returns the pixel with the biggest channel difference of two
+// images and that difference
+func maxDiff(a, b *image.RGBA) (int, int, int) {
+	maxX, maxY, biggest := 0, 0, 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca, cb := a.RGBAAt(x, y), b.RGBAAt(x, y)
+			diff := max(absDiff(ca.R, cb.R), absDiff(ca.G, cb.G), absDiff(ca.B, cb.B), absDiff(ca.A, cb.A))
+			if diff > biggest {
+				maxX, maxY, biggest = x, y, diff
+			}
+		}
+	}
+	return maxX, maxY, biggest
+}
+
+func total(delays []int) int {
+	sum := 0
+	for _, d := range delays {
+		sum += d
+	}
+	return sum
+}
+
+func TestOptimizeFrames(t *testing.T) {
+	// A palette without room for a transparent color
+	fullPalette := make(color.Palette, 256)
+	copy(fullPalette, testPalette[:clear])
+	for i := clear; i < len(fullPalette); i++ {
+		fullPalette[i] = color.RGBA{uint8(i), 50, 50, 255}
+	}
+
+	tests := []struct {
+		name        string
+		src         *gif.GIF
+		lossy       int
+		wantFrames  int
+		wantDropped int
+	}{
+		{
+			name: "moving square",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: black, patches: []patch{{square(0, 0), red}}},
+				testFrame{rect: full(8), fill: black, patches: []patch{{square(2, 2), red}}},
+				testFrame{rect: full(8), fill: black, patches: []patch{{square(4, 4), red}}},
+			),
+			wantFrames: 3,
+		},
+		{
+			name: "unchanged frames are dropped",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: white},
+				testFrame{rect: full(8), fill: white},
+				testFrame{rect: square(3, 3), fill: white},
+				testFrame{rect: full(8), fill: green},
+			),
+			wantFrames:  2,
+			wantDropped: 2,
+		},
+		{
+			name: "disposal background",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: black, disposal: gif.DisposalBackground},
+				testFrame{rect: full(8), fill: white, patches: []patch{{square(1, 1), red}}, disposal: gif.DisposalBackground},
+				testFrame{rect: full(8), fill: white},
+			),
+			wantFrames: 3,
+		},
+		{
+			name: "disposal previous",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: black},
+				testFrame{rect: square(2, 2), fill: red, disposal: gif.DisposalPrevious},
+				testFrame{rect: square(4, 4), fill: green, disposal: gif.DisposalPrevious},
+				// Only clears the green square, which the disposal removed
+				testFrame{rect: square(6, 6), fill: black},
+			),
+			wantFrames: 4,
+		},
+		{
+			name: "transparent pixels keep the previous frame",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: gray, patches: []patch{{square(0, 0), red}}},
+				testFrame{rect: full(8), fill: clear, patches: []patch{{square(6, 6), green}}},
+				testFrame{rect: image.Rect(2, 2, 6, 6), fill: clear},
+			),
+			wantFrames:  2,
+			wantDropped: 1,
+		},
+		{
+			name: "full palette without a transparent color",
+			src: buildGIF(8, fullPalette,
+				testFrame{rect: full(8), fill: black},
+				testFrame{rect: full(8), fill: black, patches: []patch{{square(2, 2), 200}}},
+				testFrame{rect: full(8), fill: 100},
+			),
+			wantFrames: 3,
+		},
+		{
+			name: "lossy keeps close colors",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: gray},
+				testFrame{rect: full(8), fill: lightGray},
+				testFrame{rect: full(8), fill: lightGray, patches: []patch{{square(4, 4), white}}},
+			),
+			lossy:       2,
+			wantFrames:  2,
+			wantDropped: 1,
+		},
+		{
+			name: "lossless keeps close colors apart",
+			src: buildGIF(8, testPalette,
+				testFrame{rect: full(8), fill: gray},
+				testFrame{rect: full(8), fill: lightGray},
+			),
+			wantFrames: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := roundTrip(t, tt.src)
+			optimized, dropped, ok := optimizeFrames(src, tt.lossy)
+			if !ok {
+				t.Fatal("optimizeFrames gave up")
+			}
+			got := roundTrip(t, optimized)
+
+			if len(got.Image) != tt.wantFrames || dropped != tt.wantDropped {
+				t.Errorf("got %d frames, %d dropped, want %d frames, %d dropped",
+					len(got.Image), dropped, tt.wantFrames, tt.wantDropped)
+			}
+			compareShown(t, src, got, tt.lossy)
+		})
+	}
+}
+
+func TestOptimizeFramesGivesUpOnTransparency(t *testing.T) {
+	// The cleared square would have to turn transparent again
+	src := roundTrip(t, buildGIF(8, testPalette,
+		testFrame{rect: full(8), fill: black},
+		testFrame{rect: square(2, 2), fill: red, disposal: gif.DisposalBackground},
+		testFrame{rect: square(6, 6), fill: green},
+	))
+	if _, _, ok := optimizeFrames(src, 0); ok {
+		t.Error("optimizeFrames optimized pixels that turn transparent")
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	frames := []testFrame{{rect: full(64), fill: gray}}
+	for i := 0; i < 16; i++ {
+		frames = append(frames, testFrame{rect: full(64), fill: gray, patches: []patch{{square(i*4, i*4), red}}})
+	}
+	frames = append(frames, frames[len(frames)-1])
+	src := buildGIF(64, testPalette, frames...)
+
+	path := filepath.Join(t.TempDir(), "test.gif")
+	if err := encodeFile(path, src); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Optimize(path, 0)
+	if err != nil {
+		t.Fatalf("Optimize error: %v", err)
+	}
+	if result.Saved() <= 0 || result.DroppedFrames != 1 {
+		t.Errorf("Optimize = %+v, want bytes saved and 1 dropped frame", result)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != result.OptimizedSize {
+		t.Errorf("file has %d bytes, result says %d", info.Size(), result.OptimizedSize)
+	}
+
+	got, err := decodeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareShown(t, roundTrip(t, src), got, 0)
+}
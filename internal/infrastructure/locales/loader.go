@@ -0,0 +1,198 @@
+package locales
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// embedded holds the built-in locale files, one per language: "ru.yaml"
+//
+//go:embed files/*.yaml
+var embedded embed.FS
+
+// formatVerbPattern matches the fmt verbs of a localized string
+var formatVerbPattern = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// Load reads the built-in locale files and then the files in dir, if it is
+// set. Files in dir add languages or replace single strings of built-in ones.
+// Every string missing in a language or broken, such as having different
+// format verbs, falls back to the string of defaultLang, which must be complete.
+func Load(dir, defaultLang string) (map[string]*domain.Locale, error) {
+	bundles := make(map[string]map[string]any)
+	if err := readFiles(embedded, "files", bundles); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := readFiles(os.DirFS(dir), ".", bundles); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, ok := bundles[defaultLang]
+	if !ok {
+		return nil, fmt.Errorf("no locale file for the default language %q", defaultLang)
+	}
+	fallback, problems := buildLocale(defaultLang, raw, nil)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("default locale %q is incomplete: %s", defaultLang, strings.Join(problems, "; "))
+	}
+
+	locales := map[string]*domain.Locale{defaultLang: fallback}
+	for lang, raw := range bundles {
+		if lang == defaultLang {
+			continue
+		}
+		locale, problems := buildLocale(lang, raw, fallback)
+		for _, problem := range problems {
+			log.Printf("Locale %s: %s, using %s", lang, problem, defaultLang)
+		}
+		locales[lang] = locale
+	}
+	return locales, nil
+}
+
+// readFiles decodes the YAML and JSON locale files of a directory into
+// bundles, merging them with bundles read before
+func readFiles(fsys fs.FS, dir string, bundles map[string]map[string]any) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read locale directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, filepath.ToSlash(filepath.Join(dir, entry.Name())))
+		if err != nil {
+			return fmt.Errorf("failed to read locale file %s: %w", entry.Name(), err)
+		}
+
+		// JSON is valid YAML, so both are decoded the same way
+		var values map[string]any
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("failed to parse locale file %s: %w", entry.Name(), err)
+		}
+
+		lang := strings.TrimSuffix(entry.Name(), ext)
+		if bundles[lang] == nil {
+			bundles[lang] = make(map[string]any, len(values))
+		}
+		for key, value := range values {
+			bundles[lang][key] = value
+		}
+	}
+	return nil
+}
+
+var (
+	stringType = reflect.TypeOf("")
+	pluralType = reflect.TypeOf(domain.PluralForms{})
+)
+
+// buildLocale fills a locale from the values of its file. Missing and
+// broken values are described in the returned problems and taken from
+// fallback, if it is set.
+func buildLocale(lang string, values map[string]any, fallback *domain.Locale) (*domain.Locale, []string) {
+	locale := &domain.Locale{Code: lang}
+	target := reflect.ValueOf(locale).Elem()
+	var fallbackValue reflect.Value
+	if fallback != nil {
+		fallbackValue = reflect.ValueOf(fallback).Elem()
+	}
+
+	var problems []string
+	known := make(map[string]bool, target.NumField())
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		if field.Tag.Get("locale") == "-" {
+			continue
+		}
+		known[field.Name] = true
+
+		value, ok := values[field.Name]
+		var problem string
+		switch {
+		case !ok:
+			problem = "missing " + field.Name
+		case field.Type == stringType:
+			problem = setString(target.Field(i), field.Name, value, fallbackValue)
+		case field.Type == pluralType:
+			problem = setPlural(target.Field(i), field.Name, lang, value)
+		}
+
+		if problem != "" {
+			problems = append(problems, problem)
+			if fallback != nil {
+				target.Field(i).Set(fallbackValue.Field(i))
+			}
+		}
+	}
+
+	for key := range values {
+		if !known[key] {
+			log.Printf("Locale %s: unknown key %s", lang, key)
+		}
+	}
+	return locale, problems
+}
+
+// setString sets a string field, checking that it has the same format
+// verbs as the fallback string. It returns a problem or an empty string.
+func setString(field reflect.Value, key string, value any, fallback reflect.Value) string {
+	str, ok := value.(string)
+	if !ok {
+		return key + " is not a string"
+	}
+	if fallback.IsValid() {
+		want := fallback.FieldByName(key).String()
+		if !slices.Equal(formatVerbPattern.FindAllString(str, -1), formatVerbPattern.FindAllString(want, -1)) {
+			return key + " has different format verbs"
+		}
+	}
+	field.SetString(str)
+	return ""
+}
+
+// setPlural sets a plural forms field, checking that every category of the
+// language is there and every form has the %d verb for the count. It
+// returns a problem or an empty string.
+func setPlural(field reflect.Value, key, lang string, value any) string {
+	raw, ok := value.(map[string]any)
+	if !ok {
+		return key + " is not a map of plural forms"
+	}
+
+	forms := make(domain.PluralForms, len(raw))
+	for category, form := range raw {
+		str, ok := form.(string)
+		if !ok {
+			return fmt.Sprintf("%s.%s is not a string", key, category)
+		}
+		if !slices.Equal(formatVerbPattern.FindAllString(str, -1), []string{"%d"}) {
+			return fmt.Sprintf("%s.%s must have a single %%d", key, category)
+		}
+		forms[domain.PluralCategory(category)] = str
+	}
+
+	for _, category := range domain.PluralCategories(lang) {
+		if _, ok := forms[category]; !ok {
+			return fmt.Sprintf("%s is missing the %s form", key, category)
+		}
+	}
+	field.Set(reflect.ValueOf(forms))
+	return ""
+}
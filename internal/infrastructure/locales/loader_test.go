@@ -0,0 +1,143 @@
+package locales
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// writeLocales writes locale files into a temporary directory
+func writeLocales(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuiltInLocalesAreComplete(t *testing.T) {
+	bundles := make(map[string]map[string]any)
+	if err := readFiles(embedded, "files", bundles); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback, problems := buildLocale("ru", bundles["ru"], nil)
+	if len(problems) > 0 {
+		t.Fatalf("ru: %v", problems)
+	}
+	for lang, values := range bundles {
+		if _, problems := buildLocale(lang, values, fallback); len(problems) > 0 {
+			t.Errorf("%s: %v", lang, problems)
+		}
+	}
+}
+
+func TestLoadFallback(t *testing.T) {
+	dir := writeLocales(t, map[string]string{
+		// A new language with a single string
+		"de.yaml": `ButtonLanguage: "🌐 Sprache"`,
+		// Broken strings of a built-in language
+		"en.yaml": `
+PartCaption: "Part %d"
+ProcessingPart: "Working on part %d of %d..."
+Seconds:
+  one: "one second"
+  other: "%d seconds"
+UpToSeconds:
+  other: "up to %d seconds"
+`,
+		// Missing plural categories of a language that has more of them
+		"uk.json": `{"Seconds": {"one": "%d секунда", "other": "%d секунди"}}`,
+	})
+
+	locales, err := Load(dir, "ru")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	ru, en, uk, de := locales["ru"], locales["en"], locales["uk"], locales["de"]
+	if ru == nil || en == nil || uk == nil || de == nil {
+		t.Fatalf("Load returned languages %v, want ru, en, uk and de", reflect.ValueOf(locales).MapKeys())
+	}
+
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"new language string", de.ButtonLanguage, "🌐 Sprache"},
+		{"new language fallback", de.HelpMessage, ru.HelpMessage},
+		{"new language code", de.Code, "de"},
+		{"different verbs", en.PartCaption, ru.PartCaption},
+		{"same verbs replace", en.ProcessingPart, "Working on part %d of %d..."},
+		{"plural without %d", en.Seconds, ru.Seconds},
+		{"plural missing one", en.UpToSeconds, ru.UpToSeconds},
+		{"plural missing few and many", uk.Seconds, ru.Seconds},
+	}
+
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Strings that weren't overridden keep the built-in translation
+	if en.HelpMessage == ru.HelpMessage {
+		t.Error("built-in en strings were replaced by the fallback")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		defaultLang string
+	}{
+		{"no default locale", nil, "xx"},
+		{"incomplete default locale", map[string]string{"xx.yaml": `ButtonLanguage: "xx"`}, "xx"},
+		{"broken default plural", map[string]string{"ru.yaml": "Seconds:\n  other: \"seconds\"\n"}, "ru"},
+		{"invalid file", map[string]string{"en.yaml": "ButtonLanguage: [\n"}, "ru"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Load(writeLocales(t, tt.files), tt.defaultLang); err == nil {
+			t.Errorf("%s: Load succeeded", tt.name)
+		}
+	}
+}
+
+func TestSetPlural(t *testing.T) {
+	tests := []struct {
+		name    string
+		lang    string
+		value   any
+		want    domain.PluralForms
+		problem bool
+	}{
+		{
+			name:  "english",
+			lang:  "en",
+			value: map[string]any{"one": "%d GIF", "other": "%d GIFs"},
+			want:  domain.PluralForms{domain.PluralOne: "%d GIF", domain.PluralOther: "%d GIFs"},
+		},
+		{"not a map", "en", "%d GIFs", nil, true},
+		{"not a string", "en", map[string]any{"one": 1, "other": "%d GIFs"}, nil, true},
+		{"two verbs", "en", map[string]any{"one": "%d GIF", "other": "%d GIFs of %d"}, nil, true},
+		{"spanish many", "es", map[string]any{"one": "%d GIF", "other": "%d GIF"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		var forms domain.PluralForms
+		problem := setPlural(reflect.ValueOf(&forms).Elem(), "GIFs", tt.lang, tt.value)
+		if (problem != "") != tt.problem {
+			t.Errorf("%s: problem %q, want a problem: %v", tt.name, problem, tt.problem)
+		}
+		if !reflect.DeepEqual(forms, tt.want) {
+			t.Errorf("%s: forms %v, want %v", tt.name, forms, tt.want)
+		}
+	}
+}
@@ -3,10 +3,17 @@ package telegram
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// InlineButton is an inline keyboard button with callback data
+type InlineButton struct {
+	Text string
+	Data string
+}
+
 // Bot wraps Telegram Bot API
 type Bot struct {
 	api *tgbotapi.BotAPI
@@ -49,6 +56,38 @@ func (b *Bot) SendMessage(chatID int64, text string, replyMarkup interface{}) (i
 	return sent.MessageID, nil
 }
 
+// ReplyMessage sends a text message in reply to another message
+func (b *Bot) ReplyMessage(chatID int64, replyToID int, text string, replyMarkup interface{}) (int, error) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyToMessageID = replyToID
+	if replyMarkup != nil {
+		msg.ReplyMarkup = replyMarkup
+	}
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// SendMessageWithButtons sends a text message with a row of inline buttons
+func (b *Bot) SendMessageWithButtons(chatID int64, text string, buttons ...InlineButton) (int, error) {
+	return b.SendMessage(chatID, text, inlineKeyboard(buttons))
+}
+
+// inlineKeyboard builds an inline keyboard from rows of buttons
+func inlineKeyboard(rows ...[]InlineButton) tgbotapi.InlineKeyboardMarkup {
+	keyboard := make([][]tgbotapi.InlineKeyboardButton, 0, len(rows))
+	for _, buttons := range rows {
+		row := make([]tgbotapi.InlineKeyboardButton, 0, len(buttons))
+		for _, button := range buttons {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+		}
+		keyboard = append(keyboard, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
 // EditMessageText edits a message text
 func (b *Bot) EditMessageText(chatID int64, messageID int, text string) error {
 	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
@@ -56,20 +95,107 @@ func (b *Bot) EditMessageText(chatID int64, messageID int, text string) error {
 	return err
 }
 
-// SendAnimation sends an animation (GIF)
-func (b *Bot) SendAnimation(chatID int64, filePath string, caption string) error {
+// EditMessageTextAndMarkup edits a message text and its inline keyboard
+func (b *Bot) EditMessageTextAndMarkup(chatID int64, messageID int, text string, markup tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	_, err := b.api.Send(msg)
+	return err
+}
+
+// EditInlineKeyboard replaces the inline keyboard of a message
+func (b *Bot) EditInlineKeyboard(chatID int64, messageID int, markup tgbotapi.InlineKeyboardMarkup) error {
+	_, err := b.api.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, markup))
+	return err
+}
+
+// SendAnimation sends an animation (GIF) with optional rows of inline
+// buttons and returns the message ID
+func (b *Bot) SendAnimation(chatID int64, filePath string, caption string, keyboard [][]InlineButton) (int, error) {
 	fileData, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return 0, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	// Telegram tells GIFs and MP4 animations apart by the file name
 	fileBytes := tgbotapi.FileBytes{
-		Name:  "animation.gif",
+		Name:  filepath.Base(filePath),
 		Bytes: fileData,
 	}
 
 	msg := tgbotapi.NewAnimation(chatID, fileBytes)
 	msg.Caption = caption
+	if len(keyboard) > 0 {
+		msg.ReplyMarkup = inlineKeyboard(keyboard...)
+	}
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// SendDocument sends a file as a document with optional rows of inline
+// buttons and returns the message ID
+func (b *Bot) SendDocument(chatID int64, filePath string, caption string, keyboard [][]InlineButton) (int, error) {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	fileBytes := tgbotapi.FileBytes{
+		Name:  filepath.Base(filePath),
+		Bytes: fileData,
+	}
+
+	msg := tgbotapi.NewDocument(chatID, fileBytes)
+	msg.Caption = caption
+	if len(keyboard) > 0 {
+		msg.ReplyMarkup = inlineKeyboard(keyboard...)
+	}
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// SendSticker sends a sticker file with optional rows of inline buttons
+// and returns the message ID
+func (b *Bot) SendSticker(chatID int64, filePath string, keyboard [][]InlineButton) (int, error) {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	fileBytes := tgbotapi.FileBytes{
+		Name:  filepath.Base(filePath),
+		Bytes: fileData,
+	}
+
+	msg := tgbotapi.NewSticker(chatID, fileBytes)
+	if len(keyboard) > 0 {
+		msg.ReplyMarkup = inlineKeyboard(keyboard...)
+	}
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// SendVideoNote sends a round video (video note)
+func (b *Bot) SendVideoNote(chatID int64, filePath string, length int) error {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	fileBytes := tgbotapi.FileBytes{
+		Name:  "videonote.mp4",
+		Bytes: fileData,
+	}
+
+	msg := tgbotapi.NewVideoNote(chatID, length, fileBytes)
 	_, err = b.api.Send(msg)
 	return err
 }
@@ -86,6 +212,13 @@ func (b *Bot) AnswerCallback(callbackID string) error {
 	return err
 }
 
+// SetCommands sets the command menu shown to users whose Telegram app uses
+// languageCode, an empty code sets the menu for all other languages
+func (b *Bot) SetCommands(languageCode string, commands []tgbotapi.BotCommand) error {
+	_, err := b.api.Request(tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), languageCode, commands...))
+	return err
+}
+
 // StopReceivingUpdates stops receiving updates
 func (b *Bot) StopReceivingUpdates() {
 	b.api.StopReceivingUpdates()
@@ -102,4 +235,3 @@ func (b *Bot) GetUpdatesChan(timeout int) tgbotapi.UpdatesChannel {
 func (b *Bot) GetSelf() tgbotapi.User {
 	return b.api.Self
 }
-
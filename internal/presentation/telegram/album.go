@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// albumDebounce is how long to wait for more photos of the same media group
+const albumDebounce = 1500 * time.Millisecond
+
+// albumPhoto is a single photo of a media group
+type albumPhoto struct {
+	message *tgbotapi.Message
+	fileID  string
+}
+
+// album is a complete media group ready for processing
+type album struct {
+	chatID    int64
+	messageID int // first message of the group
+	fileIDs   []string
+	caption   string // Telegram keeps the album caption on one of the photos
+}
+
+// pendingAlbum is a media group that is still being received
+type pendingAlbum struct {
+	chatID int64
+	photos []albumPhoto
+	timer  *time.Timer
+}
+
+// albumCollector groups photos sent as one album. Telegram delivers every
+// photo of a media group as a separate update, so photos are collected
+// until no new ones arrive for albumDebounce. A complete album comes back
+// as an update of its own, so it is handled in order with the other
+// updates of its chat and through the same middleware.
+type albumCollector struct {
+	mu      sync.Mutex
+	pending map[string]*pendingAlbum
+	ready   map[*tgbotapi.Message]*album // by the message of the album update
+	onReady func(update tgbotapi.Update)
+}
+
+// newAlbumCollector creates a collector that calls onReady with an album
+// update for every complete album
+func newAlbumCollector(onReady func(update tgbotapi.Update)) *albumCollector {
+	return &albumCollector{
+		pending: make(map[string]*pendingAlbum),
+		ready:   make(map[*tgbotapi.Message]*album),
+		onReady: onReady,
+	}
+}
+
+// Add adds a photo message to its media group and restarts the debounce timer
+func (c *albumCollector) Add(message *tgbotapi.Message, fileID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chatID := message.Chat.ID
+	key := fmt.Sprintf("%d:%s", chatID, message.MediaGroupID)
+	photo := albumPhoto{message: message, fileID: fileID}
+
+	if p, ok := c.pending[key]; ok {
+		p.photos = append(p.photos, photo)
+		p.timer.Reset(albumDebounce)
+		return
+	}
+
+	c.pending[key] = &pendingAlbum{
+		chatID: chatID,
+		photos: []albumPhoto{photo},
+		timer:  time.AfterFunc(albumDebounce, func() { c.flush(key) }),
+	}
+}
+
+// flush hands a collected media group over for processing
+func (c *albumCollector) flush(key string) {
+	c.mu.Lock()
+	p, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	// The timer may fire again after a late Reset
+	if !ok {
+		return
+	}
+
+	// Updates may arrive out of order, message IDs keep the album order
+	sort.Slice(p.photos, func(i, j int) bool {
+		return p.photos[i].message.MessageID < p.photos[j].message.MessageID
+	})
+
+	a := &album{
+		chatID:    p.chatID,
+		messageID: p.photos[0].message.MessageID,
+		fileIDs:   make([]string, len(p.photos)),
+	}
+	for i, photo := range p.photos {
+		a.fileIDs[i] = photo.fileID
+		if a.caption == "" {
+			a.caption = photo.message.Caption
+		}
+	}
+
+	// The album update carries a copy of the first message, so only this
+	// update finds the album
+	message := *p.photos[0].message
+	c.mu.Lock()
+	c.ready[&message] = a
+	c.mu.Unlock()
+
+	c.onReady(tgbotapi.Update{Message: &message})
+}
+
+// IsAlbum reports whether a message is the message of an album update
+func (c *albumCollector) IsAlbum(message *tgbotapi.Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.ready[message]
+	return ok
+}
+
+// Take removes the album of an album update message and returns it
+func (c *albumCollector) Take(message *tgbotapi.Message) (*album, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, ok := c.ready[message]
+	delete(c.ready, message)
+	return a, ok
+}
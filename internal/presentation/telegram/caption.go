@@ -0,0 +1,356 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gifmaker-bot/internal/domain"
+)
+
+// memeTextSeparator separates top and bottom captions: "TOP | BOTTOM"
+const memeTextSeparator = "|"
+
+// maxSuggestionDistance is how many typos an unknown option may have to
+// still get a "did you mean" suggestion
+const maxSuggestionDistance = 2
+
+// captionFlags are the options written as a single word
+var captionFlags = map[string]func(options *domain.ConversionOptions){
+	"reverse":   func(options *domain.ConversionOptions) { options.Reverse = newBool(true) },
+	"boomerang": func(options *domain.ConversionOptions) { options.Boomerang = newBool(true) },
+	"loop":      func(options *domain.ConversionOptions) { options.Loop = newBool(true) },
+	"customize": func(options *domain.ConversionOptions) { options.Customize = true },
+}
+
+// captionOption is an option written as key=value
+type captionOption struct {
+	expected string // allowed values shown in the error message
+	apply    func(value string, options *domain.ConversionOptions) bool
+}
+
+// captionFormats are the output formats that can be asked for in a caption
+var captionFormats = []domain.OutputFormat{domain.FormatGIF, domain.FormatMP4, domain.FormatWebP, domain.FormatSticker}
+
+// captionOptions maps option keys and their aliases to their parsers
+var captionOptions = map[string]captionOption{
+	"fps":     fpsOption,
+	"w":       widthOption,
+	"width":   widthOption,
+	"fmt":     formatOption,
+	"format":  formatOption,
+	"q":       qualityOption,
+	"quality": qualityOption,
+	"speed":   speedOption,
+	"preset":  presetOption,
+}
+
+var (
+	fpsOption = captionOption{
+		expected: fmt.Sprintf("1–%d", domain.MaxFPS),
+		apply: func(value string, options *domain.ConversionOptions) bool {
+			fps, err := strconv.Atoi(value)
+			if err != nil || !domain.IsValidFPS(fps) {
+				return false
+			}
+			options.FPS = fps
+			return true
+		},
+	}
+	widthOption = captionOption{
+		expected: fmt.Sprintf("%d–%d", domain.MinWidth, domain.MaxWidth),
+		apply: func(value string, options *domain.ConversionOptions) bool {
+			width, err := strconv.Atoi(strings.TrimSuffix(value, "px"))
+			if err != nil || !domain.IsValidWidth(width) {
+				return false
+			}
+			options.Width = width
+			return true
+		},
+	}
+	formatOption = captionOption{
+		expected: joinFormats(captionFormats),
+		apply: func(value string, options *domain.ConversionOptions) bool {
+			format := domain.OutputFormat(value)
+			if !slices.Contains(captionFormats, format) {
+				return false
+			}
+			options.Format = format
+			return true
+		},
+	}
+	qualityOption = captionOption{
+		expected: strings.Join(domain.QualityLevels, ", "),
+		apply: func(value string, options *domain.ConversionOptions) bool {
+			if !slices.Contains(domain.QualityLevels, value) {
+				return false
+			}
+			options.Quality = value
+			return true
+		},
+	}
+	presetOption = captionOption{
+		expected: "a-z, 0-9, _, -",
+		apply: func(value string, options *domain.ConversionOptions) bool {
+			if !domain.IsValidPresetName(value) {
+				return false
+			}
+			options.Preset = value
+			return true
+		},
+	}
+	speedOption = captionOption{
+		expected: formatSpeed(domain.MinSpeed) + "–" + formatSpeed(domain.MaxSpeed),
+		apply: func(value string, options *domain.ConversionOptions) bool {
+			speed, ok := parseNumber(value)
+			if !ok || !domain.IsValidSpeed(speed) {
+				return false
+			}
+			options.Speed = speed
+			return true
+		},
+	}
+)
+
+// trimRangeExample is shown when a trim range can't be parsed
+const trimRangeExample = "2.5-6"
+
+// trimRangePattern matches trim ranges in seconds: "2.5-6", "3-" or "-5"
+var trimRangePattern = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)?-(\d+(?:[.,]\d+)?)?$`)
+
+// speedPattern matches speeds written as "2x" or "x2"
+var speedPattern = regexp.MustCompile(`^(?:x(\d+(?:[.,]\d+)?)|(\d+(?:[.,]\d+)?)x)$`)
+
+// captionError describes a caption token that could not be parsed
+type captionError struct {
+	token      string
+	expected   string // allowed values for a known option with a bad value
+	suggestion string // closest known option for an unknown one
+}
+
+func (e *captionError) Error() string {
+	if e.expected != "" {
+		return fmt.Sprintf("invalid value in %q, expected %s", e.token, e.expected)
+	}
+	return fmt.Sprintf("unknown option %q", e.token)
+}
+
+// parseCaption extracts conversion options from a video caption. The first
+// line may list options ("fps=15 w=320 2.5-6 reverse fmt=webp q=high"),
+// the rest is meme text. A first line that doesn't look like options is
+// treated as text.
+func parseCaption(caption string) (domain.ConversionOptions, error) {
+	var options domain.ConversionOptions
+
+	// A command such as /videonote may come first, options follow it
+	caption = strings.TrimSpace(caption)
+	if strings.HasPrefix(caption, "/") {
+		end := strings.IndexFunc(caption, unicode.IsSpace)
+		if end < 0 {
+			end = len(caption)
+		}
+		caption = strings.TrimSpace(caption[end:])
+	}
+	if caption == "" {
+		return options, nil
+	}
+
+	firstLine, rest, _ := strings.Cut(caption, "\n")
+	tokens := strings.Fields(strings.ToLower(firstLine))
+	if isOptionsLine(firstLine, tokens) {
+		for _, token := range tokens {
+			if err := parseOptionToken(token, &options); err != nil {
+				return domain.ConversionOptions{}, err
+			}
+		}
+		caption = strings.TrimSpace(rest)
+	}
+
+	if top, bottom, ok := strings.Cut(caption, memeTextSeparator); ok {
+		options.TopText = strings.TrimSpace(top)
+		options.BottomText = strings.TrimSpace(bottom)
+	}
+
+	return options, nil
+}
+
+// isOptionsLine reports whether a caption line is meant as options: it has
+// no meme text and every token is written as an option or is a typo of
+// one. A single other word makes the line text, so "Moscow 2023-2024"
+// isn't read as a trim range.
+func isOptionsLine(line string, tokens []string) bool {
+	if strings.Contains(line, memeTextSeparator) || len(tokens) == 0 {
+		return false
+	}
+	for _, token := range tokens {
+		if !isOptionToken(token) && suggestOption(token) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isOptionToken reports whether a token is written in option syntax,
+// whether or not its value is valid
+func isOptionToken(token string) bool {
+	_, isFlag := captionFlags[token]
+	return isFlag || strings.Contains(token, "=") ||
+		isTrimRange(token) || speedPattern.MatchString(token)
+}
+
+// parseOptionToken applies a single option token to options
+func parseOptionToken(token string, options *domain.ConversionOptions) error {
+	if apply, ok := captionFlags[token]; ok {
+		apply(options)
+		return nil
+	}
+
+	if key, value, ok := strings.Cut(token, "="); ok {
+		option, known := captionOptions[key]
+		if !known {
+			suggestion := suggestOption(key)
+			if suggestion != "" && value != "" {
+				suggestion += "=" + value
+			}
+			return &captionError{token: token, suggestion: suggestion}
+		}
+		if !option.apply(value, options) {
+			return &captionError{token: token, expected: option.expected}
+		}
+		return nil
+	}
+
+	if match := speedPattern.FindStringSubmatch(token); match != nil {
+		if !speedOption.apply(match[1]+match[2], options) {
+			return &captionError{token: token, expected: speedOption.expected}
+		}
+		return nil
+	}
+
+	if isTrimRange(token) {
+		match := trimRangePattern.FindStringSubmatch(token)
+		trim, ok := parseTrimRange(match[1], match[2])
+		if !ok {
+			return &captionError{token: token, expected: trimRangeExample}
+		}
+		options.Trim = trim
+		return nil
+	}
+
+	return &captionError{token: token, suggestion: suggestOption(token)}
+}
+
+// isTrimRange reports whether a token is a trim range with at least one bound
+func isTrimRange(token string) bool {
+	return token != "-" && trimRangePattern.MatchString(token)
+}
+
+// parseTrimRange parses the bounds of a trim range, either may be empty
+func parseTrimRange(startValue, endValue string) (domain.TimeRange, bool) {
+	var trim domain.TimeRange
+	var ok bool
+	if startValue != "" {
+		if trim.Start, ok = parseNumber(startValue); !ok {
+			return trim, false
+		}
+	}
+	if endValue != "" {
+		if trim.End, ok = parseNumber(endValue); !ok || trim.End <= trim.Start {
+			return trim, false
+		}
+	}
+	return trim, trim.IsSet()
+}
+
+// newBool returns a pointer to a new bool for effect overrides
+func newBool(value bool) *bool {
+	return &value
+}
+
+// parseNumber parses a decimal number written with a dot or a comma
+func parseNumber(value string) (float64, bool) {
+	number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	return number, err == nil
+}
+
+// suggestOption returns the known flag or option key closest to an unknown
+// word, or an empty string if none is close enough. Equally close
+// candidates are picked in alphabetical order.
+func suggestOption(word string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, candidate := range suggestionCandidates {
+		distance := levenshtein(word, candidate)
+		// Short words are close to everything, allow a typo per three
+		// characters of the candidate
+		if distance < bestDistance && distance*3 <= len(candidate) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// suggestionCandidates are the flags and option keys in alphabetical order
+var suggestionCandidates = func() []string {
+	candidates := make([]string, 0, len(captionFlags)+len(captionOptions))
+	for flag := range captionFlags {
+		candidates = append(candidates, flag)
+	}
+	for key := range captionOptions {
+		candidates = append(candidates, key)
+	}
+	slices.Sort(candidates)
+	return candidates
+}()
+
+// levenshtein returns the edit distance between two strings, counting a
+// swap of two neighbouring characters as a single typo
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	distances := make([][]int, len(ra)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(rb)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			distances[i][j] = min(distances[i-1][j]+1, distances[i][j-1]+1, distances[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				distances[i][j] = min(distances[i][j], distances[i-2][j-2]+1)
+			}
+		}
+	}
+	return distances[len(ra)][len(rb)]
+}
+
+// joinFormats lists output formats for messages
+func joinFormats(formats []domain.OutputFormat) string {
+	names := make([]string, len(formats))
+	for i, format := range formats {
+		names[i] = string(format)
+	}
+	return strings.Join(names, ", ")
+}
+
+// captionErrorText returns the localized message for a caption error
+func captionErrorText(err *captionError, locale *domain.Locale) string {
+	if err.expected != "" {
+		return fmt.Sprintf(locale.CaptionBadValue, err.token, err.expected) + "\n" + locale.CaptionUsage
+	}
+
+	text := fmt.Sprintf(locale.CaptionUnknown, err.token)
+	if err.suggestion != "" {
+		text += " " + fmt.Sprintf(locale.CaptionDidYouMean, err.suggestion)
+	}
+	return text + "\n" + locale.CaptionUsage
+}
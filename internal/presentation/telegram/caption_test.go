@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gifmaker-bot/internal/domain"
+)
+
+func TestParseCaptionOptions(t *testing.T) {
+	tests := []struct {
+		caption string
+		want    domain.ConversionOptions
+	}{
+		{"2.5-6", domain.ConversionOptions{Trim: domain.TimeRange{Start: 2.5, End: 6}}},
+		{"2,5-6", domain.ConversionOptions{Trim: domain.TimeRange{Start: 2.5, End: 6}}},
+		{"3-", domain.ConversionOptions{Trim: domain.TimeRange{Start: 3}}},
+		{"-5", domain.ConversionOptions{Trim: domain.TimeRange{End: 5}}},
+		{"2x", domain.ConversionOptions{Speed: 2}},
+		{"x0.5", domain.ConversionOptions{Speed: 0.5}},
+		{"speed=1.5", domain.ConversionOptions{Speed: 1.5}},
+		{"reverse boomerang", domain.ConversionOptions{Reverse: newBool(true), Boomerang: newBool(true)}},
+		{"FPS=15 W=320px", domain.ConversionOptions{FPS: 15, Width: 320}},
+		{"fmt=webp q=high", domain.ConversionOptions{Format: domain.FormatWebP, Quality: domain.QualityHigh}},
+		{"preset=reaction-small", domain.ConversionOptions{Preset: "reaction-small"}},
+		{"/videonote fps=15 2-5", domain.ConversionOptions{FPS: 15, Trim: domain.TimeRange{Start: 2, End: 5}}},
+		{
+			"fps=15 2.5-6 loop\nTOP | BOTTOM",
+			domain.ConversionOptions{FPS: 15, Trim: domain.TimeRange{Start: 2.5, End: 6}, Loop: newBool(true), TopText: "TOP", BottomText: "BOTTOM"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCaption(tt.caption)
+		if err != nil {
+			t.Errorf("parseCaption(%q) error: %v", tt.caption, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCaption(%q) = %+v, want %+v", tt.caption, got, tt.want)
+		}
+	}
+}
+
+func TestParseCaptionText(t *testing.T) {
+	tests := []struct {
+		caption             string
+		wantTop, wantBottom string
+	}{
+		{"Moscow 2023-2024", "", ""},
+		{"when the loop finally works", "", ""},
+		{"me at 9-5", "", ""},
+		{"2x faster lol", "", ""},
+		{"reverse | psychology", "reverse", "psychology"},
+		{"/videonote", "", ""},
+		{"/videonote\nTOP | BOTTOM", "TOP", "BOTTOM"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCaption(tt.caption)
+		if err != nil {
+			t.Errorf("parseCaption(%q) error: %v", tt.caption, err)
+			continue
+		}
+		want := domain.ConversionOptions{TopText: tt.wantTop, BottomText: tt.wantBottom}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseCaption(%q) = %+v, want text only %+v", tt.caption, got, want)
+		}
+	}
+}
+
+func TestParseCaptionErrors(t *testing.T) {
+	tests := []struct {
+		caption        string
+		wantToken      string
+		wantExpected   string
+		wantSuggestion string
+	}{
+		{"fsp=15", "fsp=15", "", "fps=15"},
+		{"revrese", "revrese", "", "reverse"},
+		{"boomrang 2x", "boomrang", "", "boomerang"},
+		{"fps=100", "fps=100", fpsOption.expected, ""},
+		{"w=10", "w=10", widthOption.expected, ""},
+		{"fmt=avi", "fmt=avi", formatOption.expected, ""},
+		{"10x", "10x", speedOption.expected, ""},
+		{"6-2", "6-2", trimRangeExample, ""},
+		{"fps=15 colors=16", "colors=16", "", ""},
+	}
+
+	for _, tt := range tests {
+		_, err := parseCaption(tt.caption)
+		var captionErr *captionError
+		if !errors.As(err, &captionErr) {
+			t.Errorf("parseCaption(%q) error = %v, want a caption error", tt.caption, err)
+			continue
+		}
+		if captionErr.token != tt.wantToken || captionErr.expected != tt.wantExpected || captionErr.suggestion != tt.wantSuggestion {
+			t.Errorf("parseCaption(%q) error = %+v, want token %q, expected %q, suggestion %q",
+				tt.caption, *captionErr, tt.wantToken, tt.wantExpected, tt.wantSuggestion)
+		}
+	}
+}
+
+func TestSuggestOption(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"fsp", "fps"},
+		{"fpt", "fmt"}, // as close as fps, the first in alphabetical order wins
+		{"widht", "width"},
+		{"qualty", "quality"},
+		{"lop", "loop"},
+		{"customise", "customize"},
+		{"moscow", ""},
+		{"me", ""},
+		{"lol", ""},
+	}
+
+	for _, tt := range tests {
+		if got := suggestOption(tt.word); got != tt.want {
+			t.Errorf("suggestOption(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gifmaker-bot/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// customizeDraftTTL is how long an unfinished customization is kept
+const customizeDraftTTL = time.Hour
+
+// Choices offered in the customize keyboard
+var (
+	customizeFPS    = []int{8, 10, 15, 20}
+	customizeWidths = []int{240, 320, 480, 640}
+	customizeTrims  = []int{0, 3, 5, 10} // first seconds of the video, 0 is the whole video
+)
+
+// Customize keyboard callback data
+const (
+	callbackCustomizePrefix    = "cz_"
+	callbackCustomizeFPS       = "cz_fps_"
+	callbackCustomizeWidth     = "cz_width_"
+	callbackCustomizeFormat    = "cz_format_"
+	callbackCustomizeTrim      = "cz_trim_"
+	callbackCustomizeQuality   = "cz_quality_"
+	callbackCustomizeReverse   = "cz_reverse"
+	callbackCustomizeBoomerang = "cz_boomerang"
+	callbackCustomizeLoop      = "cz_loop"
+	callbackCustomizeConvert   = "cz_convert"
+	callbackCustomizeCancel    = "cz_cancel"
+)
+
+// customizeKey identifies the customize message of a draft
+type customizeKey struct {
+	chatID    int64
+	messageID int
+}
+
+// customizeDraft is a task waiting for the user to pick its settings
+type customizeDraft struct {
+	task      domain.ProcessingTask
+	expiresAt time.Time
+}
+
+// customizeDrafts keeps tasks that are being customized before queueing
+type customizeDrafts struct {
+	mu     sync.Mutex
+	drafts map[customizeKey]customizeDraft
+}
+
+// newCustomizeDrafts creates a new draft store
+func newCustomizeDrafts() *customizeDrafts {
+	return &customizeDrafts{
+		drafts: make(map[customizeKey]customizeDraft),
+	}
+}
+
+// Put stores the draft task of a customize message
+func (d *customizeDrafts) Put(chatID int64, messageID int, task *domain.ProcessingTask) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for key, draft := range d.drafts {
+		if now.After(draft.expiresAt) {
+			delete(d.drafts, key)
+		}
+	}
+
+	d.drafts[customizeKey{chatID: chatID, messageID: messageID}] = customizeDraft{
+		task:      *task,
+		expiresAt: now.Add(customizeDraftTTL),
+	}
+}
+
+// Update modifies a draft task and returns a copy of the result
+func (d *customizeDrafts) Update(chatID int64, messageID int, update func(task *domain.ProcessingTask)) (*domain.ProcessingTask, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := customizeKey{chatID: chatID, messageID: messageID}
+	draft, ok := d.drafts[key]
+	if !ok || time.Now().After(draft.expiresAt) {
+		return nil, false
+	}
+
+	update(&draft.task)
+	d.drafts[key] = draft
+	task := draft.task
+	return &task, true
+}
+
+// Take removes a draft and returns its task
+func (d *customizeDrafts) Take(chatID int64, messageID int) (*domain.ProcessingTask, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := customizeKey{chatID: chatID, messageID: messageID}
+	draft, ok := d.drafts[key]
+	delete(d.drafts, key)
+	if !ok || time.Now().After(draft.expiresAt) {
+		return nil, false
+	}
+	return &draft.task, true
+}
+
+// submitTask parses the options in the caption of a new task and queues
+// it or, if the user wants to customize it first, asks for its settings
+func (h *Handler) submitTask(task *domain.ProcessingTask, caption string, locale *domain.Locale) {
+	options, err := parseCaption(caption)
+	var captionErr *captionError
+	if errors.As(err, &captionErr) {
+		_, _ = h.bot.ReplyMessage(task.ChatID, task.MessageID, captionErrorText(captionErr, locale), nil)
+		return
+	}
+	saved := h.settingsSvc.Get(task.ChatID)
+
+	if options.Preset != "" {
+		preset, ok := h.presetSvc.Find(task.ChatID, options.Preset)
+		if !ok {
+			_, _ = h.bot.ReplyMessage(task.ChatID, task.MessageID, fmt.Sprintf(locale.PresetNotFound, options.Preset), nil)
+			return
+		}
+		options.PresetUsed = &preset
+	}
+	task.Options = options
+
+	// Animations use the format from the caption, then the one picked in
+	// the settings or the preset. Video notes stay as they are.
+	if task.Format == domain.FormatGIF {
+		task.Format = settingsFormat(options.Settings(saved))
+		if options.Format != "" {
+			task.Format = options.Format
+		}
+	}
+
+	customize := options.Customize || saved.Customize
+	if !customize || task.Format == domain.FormatVideoNote {
+		h.processVideoFile(task, locale)
+		return
+	}
+
+	msgID, err := h.bot.ReplyMessage(task.ChatID, task.MessageID, locale.CustomizeTitle, h.customizeKeyboard(task, locale))
+	if err != nil {
+		return
+	}
+	h.drafts.Put(task.ChatID, msgID, task)
+}
+
+// handleCustomizeCallback applies a customize keyboard button press
+func (h *Handler) handleCustomizeCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	msgID := callback.Message.MessageID
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	switch callback.Data {
+	case callbackCustomizeConvert:
+		task, ok := h.drafts.Take(chatID, msgID)
+		_ = h.bot.DeleteMessage(chatID, msgID)
+		if !ok {
+			_, _ = h.bot.SendMessage(chatID, locale.SourceExpired, nil)
+			return
+		}
+		h.processVideoFile(task, locale)
+		return
+
+	case callbackCustomizeCancel:
+		_, _ = h.drafts.Take(chatID, msgID)
+		_ = h.bot.DeleteMessage(chatID, msgID)
+		return
+	}
+
+	task, ok := h.drafts.Update(chatID, msgID, func(task *domain.ProcessingTask) {
+		applyCustomizeChoice(task, callback.Data, h.taskGIF(task))
+	})
+	if !ok {
+		_ = h.bot.DeleteMessage(chatID, msgID)
+		_, _ = h.bot.SendMessage(chatID, locale.SourceExpired, nil)
+		return
+	}
+
+	_ = h.bot.EditMessageTextAndMarkup(chatID, msgID, locale.CustomizeTitle, h.customizeKeyboard(task, locale))
+}
+
+// applyCustomizeChoice changes a draft task according to callback data.
+// Effects are toggled against gif, the settings the task would be converted
+// with, so they can turn off an effect saved in the settings too. Values
+// that are not offered in the keyboard are ignored.
+func applyCustomizeChoice(task *domain.ProcessingTask, data string, gif domain.GIFConfig) {
+	options := &task.Options
+
+	switch {
+	case data == callbackCustomizeReverse:
+		options.Reverse = newBool(!gif.Reverse)
+	case data == callbackCustomizeBoomerang:
+		options.Boomerang = newBool(!gif.Boomerang)
+	case data == callbackCustomizeLoop:
+		options.Loop = newBool(!gif.PerfectLoop)
+
+	case strings.HasPrefix(data, callbackCustomizeFPS):
+		if fps, err := strconv.Atoi(strings.TrimPrefix(data, callbackCustomizeFPS)); err == nil && slices.Contains(customizeFPS, fps) {
+			options.FPS = fps
+		}
+
+	case strings.HasPrefix(data, callbackCustomizeWidth):
+		if width, err := strconv.Atoi(strings.TrimPrefix(data, callbackCustomizeWidth)); err == nil && slices.Contains(customizeWidths, width) {
+			options.Width = width
+		}
+
+	case strings.HasPrefix(data, callbackCustomizeTrim):
+		if seconds, err := strconv.Atoi(strings.TrimPrefix(data, callbackCustomizeTrim)); err == nil && slices.Contains(customizeTrims, seconds) {
+			options.Trim = domain.TimeRange{End: float64(seconds)}
+		}
+
+	case strings.HasPrefix(data, callbackCustomizeFormat):
+		format := domain.OutputFormat(strings.TrimPrefix(data, callbackCustomizeFormat))
+		if slices.Contains(domain.AnimationFormats, format) {
+			task.Format = format
+		}
+
+	case strings.HasPrefix(data, callbackCustomizeQuality):
+		quality := strings.TrimPrefix(data, callbackCustomizeQuality)
+		if slices.Contains(domain.QualityLevels, quality) {
+			options.Quality = quality
+		}
+	}
+}
+
+// taskGIF returns the GIF settings a task would be converted with
+func (h *Handler) taskGIF(task *domain.ProcessingTask) domain.GIFConfig {
+	gif := h.effectiveGIF(task.Options.Settings(h.settingsSvc.Get(task.ChatID)))
+	task.Options.Apply(&gif)
+	return gif
+}
+
+// customizeKeyboard creates the customize keyboard for a draft task,
+// marking the settings the task would be converted with
+func (h *Handler) customizeKeyboard(task *domain.ProcessingTask, locale *domain.Locale) tgbotapi.InlineKeyboardMarkup {
+	gif := h.taskGIF(task)
+
+	fpsRow := make([]tgbotapi.InlineKeyboardButton, 0, len(customizeFPS))
+	for _, fps := range customizeFPS {
+		fpsRow = append(fpsRow, choiceButton(fmt.Sprintf("%d fps", fps),
+			callbackCustomizeFPS+strconv.Itoa(fps), fps == gif.FPS))
+	}
+
+	widthRow := make([]tgbotapi.InlineKeyboardButton, 0, len(customizeWidths))
+	for _, width := range customizeWidths {
+		widthRow = append(widthRow, choiceButton(fmt.Sprintf("%dpx", width),
+			callbackCustomizeWidth+strconv.Itoa(width), width == gif.Width))
+	}
+
+	formatRow := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.AnimationFormats))
+	for _, format := range domain.AnimationFormats {
+		formatRow = append(formatRow, choiceButton(formatLabel(format),
+			callbackCustomizeFormat+string(format), format == task.Format))
+	}
+
+	trimRow := make([]tgbotapi.InlineKeyboardButton, 0, len(customizeTrims))
+	for _, seconds := range customizeTrims {
+		label := locale.TrimWhole
+		if seconds > 0 {
+			label = fmt.Sprintf(locale.TrimFirst, seconds)
+		}
+		trimRow = append(trimRow, choiceButton(label,
+			callbackCustomizeTrim+strconv.Itoa(seconds), float64(seconds) == task.Options.Trim.End))
+	}
+
+	qualityRow := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.QualityLevels))
+	for _, quality := range domain.QualityLevels {
+		qualityRow = append(qualityRow, choiceButton(qualityLabel(quality, locale),
+			callbackCustomizeQuality+quality, quality == gif.Quality))
+	}
+
+	effectsRow := tgbotapi.NewInlineKeyboardRow(
+		choiceButton(locale.SettingsReverse, callbackCustomizeReverse, gif.Reverse),
+		choiceButton(locale.SettingsBoomerang, callbackCustomizeBoomerang, gif.Boomerang),
+		choiceButton(locale.SettingsLoop, callbackCustomizeLoop, gif.PerfectLoop),
+	)
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		fpsRow,
+		widthRow,
+		formatRow,
+		trimRow,
+		effectsRow,
+		qualityRow,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(locale.CustomizeConvert, callbackCustomizeConvert),
+			tgbotapi.NewInlineKeyboardButtonData(locale.CustomizeCancel, callbackCustomizeCancel),
+		),
+	)
+}
@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Dispatcher handles updates of different chats concurrently and updates of
+// the same chat one by one in the order they came, so a slow API call holds
+// up only its own chat
+type Dispatcher struct {
+	handle  func(update tgbotapi.Update)
+	workers int
+	backlog int // most updates waiting to be handled, newer ones are dropped
+
+	mu      sync.Mutex
+	chats   map[int64][]tgbotapi.Update // waiting updates of chats that have a worker or wait for one
+	pending int
+	ready   chan int64 // chats waiting for a worker
+	closed  bool       // Run stopped taking updates
+}
+
+// NewDispatcher creates a dispatcher that calls handle from a pool of workers
+func NewDispatcher(handle func(update tgbotapi.Update), workers, backlog int) *Dispatcher {
+	return &Dispatcher{
+		handle:  handle,
+		workers: workers,
+		backlog: backlog,
+		chats:   make(map[int64][]tgbotapi.Update),
+		// A chat waits for a worker only with updates waiting, so there
+		// are never more of them than backlog
+		ready: make(chan int64, backlog),
+	}
+}
+
+// Run handles the updates until ctx is done or the channel is closed. It
+// returns after the updates received by then are handled.
+func (d *Dispatcher) Run(ctx context.Context, updates <-chan tgbotapi.Update) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work()
+		}()
+	}
+
+	d.receive(ctx, updates)
+	d.mu.Lock()
+	d.closed = true
+	close(d.ready)
+	d.mu.Unlock()
+	wg.Wait()
+}
+
+// Submit queues an update that didn't come from Telegram. It returns false
+// if the backlog is full or Run stopped taking updates.
+func (d *Dispatcher) Submit(update tgbotapi.Update) bool {
+	return d.enqueue(update)
+}
+
+// receive queues the updates until ctx is done or the channel is closed
+func (d *Dispatcher) receive(ctx context.Context, updates <-chan tgbotapi.Update) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if !d.enqueue(update) {
+				log.Printf("Update backlog is full, dropped update %d", update.UpdateID)
+			}
+		}
+	}
+}
+
+// enqueue queues an update behind the waiting updates of its chat. It
+// returns false if the backlog is full or the dispatcher is closed.
+func (d *Dispatcher) enqueue(update tgbotapi.Update) bool {
+	var chatID int64
+	if chat := update.FromChat(); chat != nil {
+		chatID = chat.ID
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.pending >= d.backlog {
+		return false
+	}
+	d.pending++
+
+	queue, active := d.chats[chatID]
+	d.chats[chatID] = append(queue, update)
+	if !active {
+		d.ready <- chatID
+	}
+	return true
+}
+
+// work handles the updates of one chat at a time until no chats are left
+func (d *Dispatcher) work() {
+	for chatID := range d.ready {
+		for {
+			update, ok := d.next(chatID)
+			if !ok {
+				break
+			}
+			d.handle(update)
+		}
+	}
+}
+
+// next takes the oldest waiting update of a chat. When there are none, the
+// chat is released, so its next update goes to any worker.
+func (d *Dispatcher) next(chatID int64) (tgbotapi.Update, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue := d.chats[chatID]
+	if len(queue) == 0 {
+		delete(d.chats, chatID)
+		return tgbotapi.Update{}, false
+	}
+	d.chats[chatID] = queue[1:]
+	d.pending--
+	return queue[0], true
+}
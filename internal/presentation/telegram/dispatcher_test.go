@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatUpdate creates a message update from a chat
+func chatUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message:  &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}},
+	}
+}
+
+func TestDispatcherOrdersUpdatesOfAChat(t *testing.T) {
+	const chats, perChat = 4, 50
+
+	var mu sync.Mutex
+	handled := make(map[int64][]int)
+	otherChatHandled := make(chan struct{})
+	var otherChatOnce sync.Once
+
+	handle := func(update tgbotapi.Update) {
+		chatID := update.Message.Chat.ID
+		switch {
+		case chatID == 0 && update.UpdateID == 0:
+			// The first chat waits until another one is handled, which
+			// only happens if chats are handled in parallel
+			select {
+			case <-otherChatHandled:
+			case <-time.After(5 * time.Second):
+				t.Error("other chats were not handled while the first one was busy")
+			}
+		case chatID != 0:
+			otherChatOnce.Do(func() { close(otherChatHandled) })
+		}
+
+		mu.Lock()
+		handled[chatID] = append(handled[chatID], update.UpdateID)
+		mu.Unlock()
+	}
+
+	updates := make(chan tgbotapi.Update)
+	go func() {
+		defer close(updates)
+		for i := 0; i < perChat; i++ {
+			for chat := 0; chat < chats; chat++ {
+				updates <- chatUpdate(i*chats+chat, int64(chat))
+			}
+		}
+	}()
+
+	NewDispatcher(handle, 2, chats*perChat).Run(context.Background(), updates)
+
+	for chat := int64(0); chat < chats; chat++ {
+		ids := handled[chat]
+		if len(ids) != perChat {
+			t.Fatalf("chat %d: handled %d updates, want %d", chat, len(ids), perChat)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Fatalf("chat %d: update %d handled after %d", chat, ids[i], ids[i-1])
+			}
+		}
+	}
+}
+
+func TestDispatcherDropsUpdatesOverBacklog(t *testing.T) {
+	d := NewDispatcher(func(tgbotapi.Update) {}, 1, 3)
+
+	// Without running workers nothing leaves the backlog
+	for i := 0; i < 3; i++ {
+		if !d.enqueue(chatUpdate(i, int64(i%2))) {
+			t.Fatalf("update %d dropped with room in the backlog", i)
+		}
+	}
+	if d.enqueue(chatUpdate(3, 0)) {
+		t.Fatal("update accepted over the backlog")
+	}
+
+	// A handled update makes room for the next one
+	if _, ok := d.next(0); !ok {
+		t.Fatal("no update waiting in chat 0")
+	}
+	if !d.enqueue(chatUpdate(4, 1)) {
+		t.Fatal("update dropped after the backlog had room again")
+	}
+}
+
+func TestDispatcherRunHandlesQueuedUpdatesAfterCancel(t *testing.T) {
+	const count = 10
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled []int
+	handle := func(update tgbotapi.Update) {
+		if update.UpdateID == 0 {
+			<-release
+		}
+		mu.Lock()
+		handled = append(handled, update.UpdateID)
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan tgbotapi.Update)
+	done := make(chan struct{})
+	go func() {
+		NewDispatcher(handle, 2, count).Run(ctx, updates)
+		close(done)
+	}()
+
+	// Every update is received before the next send returns, the channel
+	// isn't buffered
+	for i := 0; i < count; i++ {
+		updates <- chatUpdate(i, 1)
+	}
+	cancel()
+
+	select {
+	case <-done:
+		t.Fatal("Run returned before the queued updates were handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the context was cancelled")
+	}
+	if len(handled) != count {
+		t.Fatalf("handled %d updates, want %d", len(handled), count)
+	}
+	for i, id := range handled {
+		if id != i {
+			t.Fatalf("handled update %d at position %d", id, i)
+		}
+	}
+}
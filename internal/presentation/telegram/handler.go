@@ -2,6 +2,7 @@ package telegram
 
 import (
 	"fmt"
+	"log"
 	"path/filepath"
 	"strings"
 
@@ -18,7 +19,17 @@ type Handler struct {
 	bot         *telegram.Bot
 	queueMgr    *usecase.QueueManager
 	localeSvc   *service.LocaleService
+	settingsSvc *service.SettingsService
+	presetSvc   *service.PresetService
+	sources     *domain.VideoSourceStore
 	config      *domain.Config
+	rateLimiter *domain.RateLimiter
+	router      *router
+	handle      func(update tgbotapi.Update)
+	submit      func(update tgbotapi.Update) bool // queues an update made by the bot itself
+	albums      *albumCollector
+	textWizard  *textWizard
+	drafts      *customizeDrafts
 }
 
 // NewHandler creates a new Telegram handler
@@ -26,95 +37,202 @@ func NewHandler(
 	bot *telegram.Bot,
 	queueMgr *usecase.QueueManager,
 	localeSvc *service.LocaleService,
+	settingsSvc *service.SettingsService,
+	presetSvc *service.PresetService,
+	sources *domain.VideoSourceStore,
+	rateLimiter *domain.RateLimiter,
 	config *domain.Config,
 ) *Handler {
-	return &Handler{
-		bot:       bot,
-		queueMgr:  queueMgr,
-		localeSvc: localeSvc,
-		config:    config,
+	h := &Handler{
+		bot:         bot,
+		queueMgr:    queueMgr,
+		localeSvc:   localeSvc,
+		settingsSvc: settingsSvc,
+		presetSvc:   presetSvc,
+		sources:     sources,
+		config:      config,
+		rateLimiter: rateLimiter,
+		textWizard:  newTextWizard(),
+		drafts:      newCustomizeDrafts(),
 	}
+	h.albums = newAlbumCollector(h.submitUpdate)
+	h.router = h.routes()
+	h.handle = h.router.Handler()
+	return h
 }
 
 // HandleUpdate handles a Telegram update
 func (h *Handler) HandleUpdate(update tgbotapi.Update) {
-	// Handle callback queries (button presses)
-	if update.CallbackQuery != nil {
-		h.handleCallbackQuery(update.CallbackQuery)
-		return
+	h.handle(update)
+
+	// An album update stopped by the middleware leaves its album behind
+	if update.Message != nil {
+		_, _ = h.albums.Take(update.Message)
 	}
+}
+
+// UseDispatcher makes the updates the bot makes itself, such as complete
+// albums, go through the dispatcher like the ones from Telegram
+func (h *Handler) UseDispatcher(d *Dispatcher) {
+	h.submit = d.Submit
+}
 
-	if update.Message == nil {
+// submitUpdate queues an update made by the bot itself. Without a
+// dispatcher it is handled right away.
+func (h *Handler) submitUpdate(update tgbotapi.Update) {
+	if h.submit == nil {
+		h.HandleUpdate(update)
 		return
 	}
+	if !h.submit(update) {
+		log.Printf("Update backlog is full, dropped an album from chat %d", update.Message.Chat.ID)
+		_, _ = h.albums.Take(update.Message)
+	}
+}
 
-	chatID := update.Message.Chat.ID
+// handleLanguageCallback switches the language of a chat
+func (h *Handler) handleLanguageCallback(callback *tgbotapi.CallbackQuery, _ *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	h.localeSvc.SetLanguage(chatID, strings.TrimPrefix(callback.Data, callbackLanguagePrefix))
 	locale := h.localeSvc.GetLocale(chatID)
 
-	// Handle text commands and buttons
-	if update.Message.Text != "" {
-		h.handleTextMessage(chatID, update.Message.Text, locale)
-		return
+	// Answer callback
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	// Send confirmation
+	keyboard := CreateMainKeyboard(locale)
+	_, _ = h.bot.SendMessage(chatID, locale.LanguageChanged, keyboard)
+
+	// Delete language selection message
+	_ = h.bot.DeleteMessage(chatID, callback.Message.MessageID)
+}
+
+// handleStartCommand greets the user and shows the main keyboard
+func (h *Handler) handleStartCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	keyboard := CreateMainKeyboard(locale)
+	_, _ = h.bot.SendMessage(message.Chat.ID, locale.StartMessage, keyboard)
+}
+
+// handleHelpCommand sends the help text
+func (h *Handler) handleHelpCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	helpText := fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s",
+		locale.HelpTitle,
+		locale.HelpDescription,
+		locale.HelpUsage,
+		locale.HelpSlideshow,
+		locale.HelpVideoNote,
+		locale.HelpText,
+		locale.HelpEffects,
+		locale.HelpLimits,
+		locale.HelpLanguage)
+	keyboard := CreateMainKeyboard(locale)
+	_, _ = h.bot.SendMessage(message.Chat.ID, helpText, keyboard)
+}
+
+// handleLanguageCommand offers the loaded languages
+func (h *Handler) handleLanguageCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	keyboard := CreateLanguageKeyboard(h.localeSvc.Languages())
+	_, _ = h.bot.SendMessage(message.Chat.ID, locale.SelectLanguage, keyboard)
+}
+
+// handleTextCommand starts the caption wizard
+func (h *Handler) handleTextCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	h.textWizard.Start(message.Chat.ID)
+	_, _ = h.bot.SendMessage(message.Chat.ID, locale.TextAskTop, nil)
+}
+
+// handleNoTextCommand removes the saved captions
+func (h *Handler) handleNoTextCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	h.settingsSvc.SetText(message.Chat.ID, "", "")
+	_, _ = h.bot.SendMessage(message.Chat.ID, locale.TextCleared, nil)
+}
+
+// handleTextMessage takes a text that is not a command as a reply to the
+// caption wizard
+func (h *Handler) handleTextMessage(message *tgbotapi.Message, locale *domain.Locale) {
+	chatID := message.Chat.ID
+	value := strings.TrimSpace(message.Text)
+	if value == textSkip {
+		value = ""
 	}
 
-	// Handle video messages
-	if update.Message.Video != nil {
-		h.handleVideoMessage(update.Message, locale)
+	state, ok := h.textWizard.Advance(chatID, value)
+	if !ok {
 		return
 	}
 
-	// Handle document messages
-	if update.Message.Document != nil {
-		h.handleDocumentMessage(update.Message, locale)
-		return
+	switch state.step {
+	case textStepTop:
+		_, _ = h.bot.SendMessage(chatID, locale.TextAskBottom, nil)
+	case textStepBottom:
+		h.settingsSvc.SetText(chatID, state.top, value)
+		if state.top == "" && value == "" {
+			_, _ = h.bot.SendMessage(chatID, locale.TextCleared, nil)
+		} else {
+			_, _ = h.bot.SendMessage(chatID, locale.TextSaved, nil)
+		}
 	}
 }
 
-func (h *Handler) handleCallbackQuery(callback *tgbotapi.CallbackQuery) {
-	chatID := callback.Message.Chat.ID
-
-	if strings.HasPrefix(callback.Data, "lang_") {
-		lang := strings.TrimPrefix(callback.Data, "lang_")
-		h.localeSvc.SetLanguage(chatID, lang)
-		locale := h.localeSvc.GetLocale(chatID)
+func (h *Handler) handleVideoMessage(message *tgbotapi.Message, locale *domain.Locale) {
+	task := newVideoTask(message, message.Video.FileID)
+	if isVideoNoteCommand(message.Caption) {
+		task.Format = domain.FormatVideoNote
+	}
+	h.submitTask(task, message.Caption, locale)
+}
 
-		// Answer callback
-		_ = h.bot.AnswerCallback(callback.ID)
+func (h *Handler) handleVideoNoteMessage(message *tgbotapi.Message, locale *domain.Locale) {
+	task := newVideoTask(message, message.VideoNote.FileID)
+	task.IsVideoNote = true
+	h.submitTask(task, message.Caption, locale)
+}
 
-		// Send confirmation
-		keyboard := CreateMainKeyboard()
-		_, _ = h.bot.SendMessage(chatID, locale.LanguageChanged, keyboard)
+// handleVideoNoteCommand turns the replied-to video into a video note
+func (h *Handler) handleVideoNoteCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	reply := message.ReplyToMessage
+	if reply == nil || (reply.Video == nil && reply.VideoNote == nil) {
+		_, _ = h.bot.SendMessage(message.Chat.ID, locale.VideoNoteUsage, nil)
+		return
+	}
 
-		// Delete language selection message
-		_ = h.bot.DeleteMessage(chatID, callback.Message.MessageID)
+	fileID := ""
+	if reply.Video != nil {
+		fileID = reply.Video.FileID
+	} else {
+		fileID = reply.VideoNote.FileID
 	}
+
+	task := newVideoTask(message, fileID)
+	task.Format = domain.FormatVideoNote
+	h.processVideoFile(task, locale)
 }
 
-func (h *Handler) handleTextMessage(chatID int64, text string, locale *domain.Locale) {
-	switch text {
-	case "/start":
-		keyboard := CreateMainKeyboard()
-		_, _ = h.bot.SendMessage(chatID, locale.StartMessage, keyboard)
+func (h *Handler) handlePhotoMessage(message *tgbotapi.Message, locale *domain.Locale) {
+	if message.MediaGroupID == "" {
+		_, _ = h.bot.SendMessage(message.Chat.ID, locale.SendAlbumMessage, nil)
+		return
+	}
 
-	case "🌐 Язык / Language", "/language", "/lang":
-		keyboard := CreateLanguageKeyboard()
-		_, _ = h.bot.SendMessage(chatID, locale.SelectLanguage, keyboard)
+	// The last size is the largest one
+	photo := message.Photo[len(message.Photo)-1]
+	h.albums.Add(message, photo.FileID)
+}
 
-	case "📖 Справка / Help", "/help":
-		helpText := fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s\n\n%s",
-			locale.HelpTitle,
-			locale.HelpDescription,
-			locale.HelpUsage,
-			locale.HelpLimits,
-			locale.HelpLanguage)
-		keyboard := CreateMainKeyboard()
-		_, _ = h.bot.SendMessage(chatID, helpText, keyboard)
+// handleAlbumMessage queues a complete album as a single slideshow task
+func (h *Handler) handleAlbumMessage(message *tgbotapi.Message, locale *domain.Locale) {
+	a, ok := h.albums.Take(message)
+	if !ok {
+		return
 	}
-}
 
-func (h *Handler) handleVideoMessage(message *tgbotapi.Message, locale *domain.Locale) {
-	fileID := message.Video.FileID
-	h.processVideoFile(message.Chat.ID, message.MessageID, fileID, locale)
+	task := &domain.ProcessingTask{
+		MessageID:    a.messageID,
+		ChatID:       a.chatID,
+		PhotoFileIDs: a.fileIDs,
+		Format:       domain.FormatGIF,
+	}
+	h.submitTask(task, a.caption, locale)
 }
 
 func (h *Handler) handleDocumentMessage(message *tgbotapi.Message, locale *domain.Locale) {
@@ -137,15 +255,43 @@ func (h *Handler) handleDocumentMessage(message *tgbotapi.Message, locale *domai
 	}
 
 	if isVideo {
-		fileID := message.Document.FileID
-		h.processVideoFile(message.Chat.ID, message.MessageID, fileID, locale)
+		task := newVideoTask(message, message.Document.FileID)
+		if isVideoNoteCommand(message.Caption) {
+			task.Format = domain.FormatVideoNote
+		}
+		h.submitTask(task, message.Caption, locale)
 	} else {
-		keyboard := CreateMainKeyboard()
+		keyboard := CreateMainKeyboard(locale)
 		_, _ = h.bot.SendMessage(message.Chat.ID, locale.SendVideoMessage, keyboard)
 	}
 }
 
-func (h *Handler) processVideoFile(chatID int64, messageID int, fileID string, locale *domain.Locale) {
+// textSkip leaves a caption empty in the caption wizard
+const textSkip = "-"
+
+// newVideoTask creates a GIF conversion task for a file sent in a message
+func newVideoTask(message *tgbotapi.Message, fileID string) *domain.ProcessingTask {
+	return &domain.ProcessingTask{
+		MessageID:   message.MessageID,
+		ChatID:      message.Chat.ID,
+		VideoFileID: fileID,
+		Format:      domain.FormatGIF,
+	}
+}
+
+// isVideoNoteCommand reports whether a caption asks for a video note
+func isVideoNoteCommand(caption string) bool {
+	fields := strings.Fields(caption)
+	if len(fields) == 0 {
+		return false
+	}
+	command, _, _ := strings.Cut(fields[0], "@")
+	return command == "/videonote"
+}
+
+func (h *Handler) processVideoFile(task *domain.ProcessingTask, locale *domain.Locale) {
+	chatID := task.ChatID
+
 	// Determine queue position and send status
 	queuePos := h.queueMgr.GetQueuePosition(chatID)
 
@@ -161,28 +307,14 @@ func (h *Handler) processVideoFile(chatID int64, messageID int, fileID string, l
 		return
 	}
 
-	// Create task
-	task := &domain.ProcessingTask{
-		MessageID: messageID,
-		ChatID:    chatID,
-		VideoFileID: fileID,
-		StatusMsgID: statusMsgID,
-	}
-
+	task.StatusMsgID = statusMsgID
 	h.queueMgr.AddTask(task)
 }
 
 func (h *Handler) sendStatusMessage(chatID int64, position int, locale *domain.Locale) (int, error) {
-	var text string
-	if position == 0 {
-		text = locale.Processing
-	} else {
-		if position == 1 {
-			text = fmt.Sprintf(locale.InQueue, position)
-		} else {
-			text = fmt.Sprintf(locale.InQueuePlural, position)
-		}
+	text := locale.Processing
+	if position > 0 {
+		text = locale.Plural(locale.InQueue, position)
 	}
 	return h.bot.SendMessage(chatID, text, nil)
 }
-
@@ -1,26 +1,262 @@
 package telegram
 
-import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+import (
+	"fmt"
+	"strconv"
+	"strings"
 
-// CreateMainKeyboard creates the main keyboard with language and help buttons
-func CreateMainKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	"gifmaker-bot/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// CreateMainKeyboard creates the main keyboard with the language and help
+// buttons of a locale
+func CreateMainKeyboard(locale *domain.Locale) tgbotapi.ReplyKeyboardMarkup {
 	keyboard := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("🌐 Язык / Language"),
-			tgbotapi.NewKeyboardButton("📖 Справка / Help"),
+			tgbotapi.NewKeyboardButton(locale.ButtonLanguage),
+			tgbotapi.NewKeyboardButton(locale.HelpMessage),
 		),
 	)
 	keyboard.ResizeKeyboard = true
 	return keyboard
 }
 
-// CreateLanguageKeyboard creates the language selection keyboard
-func CreateLanguageKeyboard() tgbotapi.InlineKeyboardMarkup {
-	return tgbotapi.NewInlineKeyboardMarkup(
+// languageButtonsPerRow is how many languages share a row of the language keyboard
+const languageButtonsPerRow = 2
+
+// CreateLanguageKeyboard creates the language selection keyboard from the
+// loaded locales
+func CreateLanguageKeyboard(languages []*domain.Locale) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, locale := range languages {
+		button := tgbotapi.NewInlineKeyboardButtonData(locale.LanguageName, callbackLanguagePrefix+locale.Code)
+		if i%languageButtonsPerRow == 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// settingsSpeeds are the playback speeds offered in the settings keyboard
+var settingsSpeeds = []float64{0.5, 1, 1.5, 2, 3}
+
+// Settings keyboard callback data
+const (
+	callbackSettingsPrefix    = "set_"
+	callbackSettingsSpeed     = "set_speed_"
+	callbackSettingsReverse   = "set_reverse"
+	callbackSettingsBoomerang = "set_boomerang"
+	callbackSettingsLoop      = "set_loop"
+	callbackSettingsText      = "set_text"
+	callbackSettingsCustomize = "set_customize"
+	callbackSettingsAutoCrop  = "set_autocrop"
+	callbackSettingsReframe   = "set_reframe_"
+	callbackSettingsQuality   = "set_quality_"
+	callbackSettingsDither    = "set_dither_"
+	callbackSettingsPalette   = "set_palette_"
+	callbackSettingsFPS       = "set_fps_"
+	callbackSettingsWidth     = "set_width_"
+	callbackSettingsFormat    = "set_format_"
+)
+
+// CreateSettingsKeyboard creates the conversion settings keyboard for the
+// effective GIF settings and output format of a user
+func CreateSettingsKeyboard(gif domain.GIFConfig, format domain.OutputFormat, customize bool, locale *domain.Locale) tgbotapi.InlineKeyboardMarkup {
+	speedRow := make([]tgbotapi.InlineKeyboardButton, 0, len(settingsSpeeds))
+	for _, speed := range settingsSpeeds {
+		data := callbackSettingsSpeed + strconv.FormatFloat(speed, 'f', -1, 64)
+		speedRow = append(speedRow, choiceButton(formatSpeed(speed), data, speed == gif.PlaybackSpeed()))
+	}
+
+	// Frame rates and widths are the same as in the customize keyboard
+	fpsRow := make([]tgbotapi.InlineKeyboardButton, 0, len(customizeFPS))
+	for _, fps := range customizeFPS {
+		fpsRow = append(fpsRow, choiceButton(fmt.Sprintf("%d fps", fps),
+			callbackSettingsFPS+strconv.Itoa(fps), fps == gif.FPS))
+	}
+
+	widthRow := make([]tgbotapi.InlineKeyboardButton, 0, len(customizeWidths))
+	for _, width := range customizeWidths {
+		widthRow = append(widthRow, choiceButton(fmt.Sprintf("%dpx", width),
+			callbackSettingsWidth+strconv.Itoa(width), width == gif.Width))
+	}
+
+	formatRow := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.AnimationFormats))
+	for _, f := range domain.AnimationFormats {
+		formatRow = append(formatRow, choiceButton(formatLabel(f),
+			callbackSettingsFormat+string(f), f == format))
+	}
+
+	reframe := effectiveReframe(gif)
+	var reframeRows [][]tgbotapi.InlineKeyboardButton
+	for i, mode := range domain.ReframeModes {
+		button := choiceButton(reframeLabel(mode, locale), callbackSettingsReframe+string(mode), mode == reframe)
+		// Original on its own row, then 1:1 and 9:16 variants side by side
+		if i == 0 || i%2 == 1 {
+			reframeRows = append(reframeRows, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			reframeRows[len(reframeRows)-1] = append(reframeRows[len(reframeRows)-1], button)
+		}
+	}
+
+	qualityRow := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.QualityLevels))
+	for _, quality := range domain.QualityLevels {
+		qualityRow = append(qualityRow, choiceButton(qualityLabel(quality, locale),
+			callbackSettingsQuality+quality, quality == gif.Quality))
+	}
+
+	ditherRow := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.DitherModes))
+	for _, mode := range domain.DitherModes {
+		ditherRow = append(ditherRow, choiceButton(ditherLabel(mode),
+			callbackSettingsDither+string(mode), mode == gif.Dither))
+	}
+
+	paletteRow := make([]tgbotapi.InlineKeyboardButton, 0, len(domain.PaletteModes))
+	for _, mode := range domain.PaletteModes {
+		paletteRow = append(paletteRow, choiceButton(paletteLabel(mode, locale),
+			callbackSettingsPalette+string(mode), mode == effectivePaletteMode(gif)))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		speedRow,
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🇷🇺 Русский", "lang_ru"),
-			tgbotapi.NewInlineKeyboardButtonData("🇬🇧 English", "lang_en"),
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s: %s", locale.SettingsReverse, formatToggle(gif.Reverse, locale)),
+				callbackSettingsReverse),
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s: %s", locale.SettingsBoomerang, formatToggle(gif.Boomerang, locale)),
+				callbackSettingsBoomerang),
 		),
-	)
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s: %s", locale.SettingsLoop, formatToggle(gif.PerfectLoop, locale)),
+				callbackSettingsLoop),
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s: %s", locale.SettingsAutoCrop, formatToggle(gif.AutoCrop, locale)),
+				callbackSettingsAutoCrop),
+		),
+	}
+	rows = append(rows, fpsRow, widthRow, formatRow)
+	rows = append(rows, reframeRows...)
+	rows = append(rows, qualityRow, ditherRow, paletteRow)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%s: %s", locale.SettingsCustomize, formatToggle(customize, locale)),
+			callbackSettingsCustomize),
+	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(locale.SettingsText, callbackSettingsText),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// choiceButton creates a button of a choice row, marking the selected one
+func choiceButton(label, data string, selected bool) tgbotapi.InlineKeyboardButton {
+	if selected {
+		label = "✅ " + label
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(label, data)
+}
+
+// effectiveReframe returns the reframe mode, treating unset as none
+func effectiveReframe(gif domain.GIFConfig) domain.ReframeMode {
+	if gif.Reframe == "" {
+		return domain.ReframeNone
+	}
+	return gif.Reframe
+}
+
+// effectivePaletteMode returns the palette mode, treating unset as global
+func effectivePaletteMode(gif domain.GIFConfig) domain.PaletteMode {
+	if gif.PaletteMode == "" {
+		return domain.PaletteGlobal
+	}
+	return gif.PaletteMode
+}
+
+// reframeLabel returns the localized name of a reframe mode
+func reframeLabel(mode domain.ReframeMode, locale *domain.Locale) string {
+	switch mode {
+	case domain.ReframeSquare:
+		return locale.ReframeSquare
+	case domain.ReframeVertical:
+		return locale.ReframeVertical
+	case domain.ReframeSquareBlur:
+		return locale.ReframeSquareBg
+	case domain.ReframeVerticalBlur:
+		return locale.ReframeVerticalBg
+	default:
+		return locale.ReframeNone
+	}
+}
+
+// formatLabel returns the short name of an output format
+func formatLabel(format domain.OutputFormat) string {
+	return strings.ToUpper(format.Extension())
+}
+
+// widthLabel formats an output width, 0 keeps the width of the video
+func widthLabel(width int, locale *domain.Locale) string {
+	if width <= 0 {
+		return locale.WidthAuto
+	}
+	return fmt.Sprintf("%dpx", width)
+}
+
+// qualityLabel returns the localized name of a quality preset
+func qualityLabel(quality string, locale *domain.Locale) string {
+	switch quality {
+	case domain.QualityLow:
+		return locale.QualityLow
+	case domain.QualityMedium:
+		return locale.QualityMedium
+	case domain.QualityHigh:
+		return locale.QualityHigh
+	default:
+		return quality
+	}
+}
+
+// ditherLabel returns the short name of a dithering mode
+func ditherLabel(mode domain.DitherMode) string {
+	switch mode {
+	case domain.DitherFloydSteinberg:
+		return "floyd"
+	case "":
+		return "-"
+	default:
+		return string(mode)
+	}
 }
 
+// paletteLabel returns the localized name of a palette mode
+func paletteLabel(mode domain.PaletteMode, locale *domain.Locale) string {
+	switch mode {
+	case domain.PaletteDiff:
+		return locale.PaletteDiff
+	case domain.PaletteSingle:
+		return locale.PaletteSingle
+	case domain.PaletteScene:
+		return locale.PaletteScene
+	default:
+		return locale.PaletteGlobal
+	}
+}
+
+// formatSpeed formats a playback speed as "1.5×"
+func formatSpeed(speed float64) string {
+	return strconv.FormatFloat(speed, 'f', -1, 64) + "×"
+}
+
+// formatToggle formats an on/off setting
+func formatToggle(on bool, locale *domain.Locale) string {
+	if on {
+		return locale.SettingOn
+	}
+	return locale.SettingOff
+}
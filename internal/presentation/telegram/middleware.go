@@ -0,0 +1,121 @@
+package telegram
+
+import (
+	"log"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recoverPanics keeps the bot running when handling an update panics
+func recoverPanics(next updateHandler) updateHandler {
+	return func(ctx *updateContext) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("Panic handling update %d from chat %d: %v\n%s", ctx.update.UpdateID, ctx.chatID, err, debug.Stack())
+			}
+		}()
+		next(ctx)
+	}
+}
+
+// logUpdates logs what every update is and how long handling it took
+func logUpdates(next updateHandler) updateHandler {
+	return func(ctx *updateContext) {
+		start := time.Now()
+		next(ctx)
+		log.Printf("Update %d from chat %d (%s) handled in %s",
+			ctx.update.UpdateID, ctx.chatID, describeUpdate(ctx.update), time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// describeUpdate names the kind of an update for logs
+func describeUpdate(update tgbotapi.Update) string {
+	if update.CallbackQuery != nil {
+		return "callback " + update.CallbackQuery.Data
+	}
+
+	message := update.Message
+	switch {
+	case message == nil:
+		return "other"
+	case message.IsCommand():
+		return "command /" + message.Command()
+	case message.Text != "":
+		return "text"
+	case message.Video != nil:
+		return "video"
+	case message.VideoNote != nil:
+		return "video note"
+	case len(message.Photo) > 0:
+		return "photo"
+	case message.Document != nil:
+		return "document"
+	default:
+		return "other message"
+	}
+}
+
+// checkAccess drops the updates of banned users and, when the config lists
+// allowed users, of everyone else
+func (h *Handler) checkAccess(next updateHandler) updateHandler {
+	return func(ctx *updateContext) {
+		if !h.config.Access.Allows(ctx.userID) {
+			log.Printf("Dropped update %d from user %d without access", ctx.update.UpdateID, ctx.userID)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// injectLocale sets the locale of the chat of an update. New users get
+// the language of their Telegram app.
+func (h *Handler) injectLocale(next updateHandler) updateHandler {
+	return func(ctx *updateContext) {
+		if from := ctx.update.SentFrom(); ctx.chatID != 0 && from != nil {
+			h.localeSvc.DetectLanguage(ctx.chatID, from.LanguageCode)
+		}
+		ctx.locale = h.localeSvc.GetLocale(ctx.chatID)
+		next(ctx)
+	}
+}
+
+// limitRate drops the updates of users who send too many, telling them
+// once. Pressed buttons are answered so they don't keep spinning. An album
+// counts as one update. It runs before injectLocale, so dropped updates
+// don't detect the language of new users.
+func (h *Handler) limitRate(next updateHandler) updateHandler {
+	return func(ctx *updateContext) {
+		var mediaGroupID string
+		if message := ctx.update.Message; message != nil {
+			mediaGroupID = message.MediaGroupID
+		}
+		allowed, notify := h.rateLimiter.Allow(ctx.userID, mediaGroupID)
+		if allowed {
+			next(ctx)
+			return
+		}
+
+		if callback := ctx.update.CallbackQuery; callback != nil {
+			_ = h.bot.AnswerCallback(callback.ID)
+		}
+		if notify && ctx.chatID != 0 {
+			_, _ = h.bot.SendMessage(ctx.chatID, h.localeSvc.GetLocale(ctx.chatID).RateLimited, nil)
+		}
+	}
+}
+
+// interruptTextWizard cancels the caption wizard when a command or a main
+// keyboard button is sent instead of a caption. Commands for other bots
+// don't count.
+func (h *Handler) interruptTextWizard(next updateHandler) updateHandler {
+	return func(ctx *updateContext) {
+		if message := ctx.update.Message; message != nil {
+			if _, ok := h.router.Route(message); ok || (message.IsCommand() && !h.router.ForOtherBot(message)) {
+				h.textWizard.Cancel(ctx.chatID)
+			}
+		}
+		next(ctx)
+	}
+}
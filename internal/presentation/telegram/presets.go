@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Preset keyboard callback data
+const (
+	callbackPresetPrefix = "preset_"
+	callbackPresetApply  = "preset_apply_"
+	callbackPresetDelete = "preset_del_"
+)
+
+// handleSavePresetCommand saves the effective settings of a user as a preset
+func (h *Handler) handleSavePresetCommand(message *tgbotapi.Message, args []string, locale *domain.Locale) {
+	chatID := message.Chat.ID
+	name := strings.ToLower(args[0])
+	if !domain.IsValidPresetName(name) {
+		_, _ = h.bot.SendMessage(chatID, locale.PresetUsage, nil)
+		return
+	}
+
+	if !h.presetSvc.Save(chatID, name, h.presetFromSettings(h.settingsSvc.Get(chatID))) {
+		_, _ = h.bot.SendMessage(chatID, locale.Plural(locale.PresetLimit, domain.MaxUserPresets), nil)
+		return
+	}
+	_, _ = h.bot.SendMessage(chatID, fmt.Sprintf(locale.PresetSaved, name, name), nil)
+}
+
+// handlePresetsCommand lists the presets available to a user
+func (h *Handler) handlePresetsCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	chatID := message.Chat.ID
+	keyboard, ok := h.presetsKeyboard(chatID)
+	if !ok {
+		_, _ = h.bot.SendMessage(chatID, locale.PresetsEmpty, nil)
+		return
+	}
+	_, _ = h.bot.SendMessage(chatID, locale.PresetsTitle, keyboard)
+}
+
+// handlePresetCallback applies or deletes a preset
+func (h *Handler) handlePresetCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	switch data := callback.Data; {
+	case strings.HasPrefix(data, callbackPresetApply):
+		name := strings.TrimPrefix(data, callbackPresetApply)
+		preset, ok := h.presetSvc.Find(chatID, name)
+		if !ok {
+			_, _ = h.bot.SendMessage(chatID, fmt.Sprintf(locale.PresetNotFound, name), nil)
+			return
+		}
+		// Whether to customize before converting is not part of a preset
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			customize := settings.Customize
+			*settings = preset
+			settings.Customize = customize
+		})
+		_, _ = h.bot.SendMessage(chatID, fmt.Sprintf(locale.PresetApplied, name), nil)
+
+	case strings.HasPrefix(data, callbackPresetDelete):
+		name := strings.TrimPrefix(data, callbackPresetDelete)
+		if h.presetSvc.Delete(chatID, name) {
+			_, _ = h.bot.SendMessage(chatID, fmt.Sprintf(locale.PresetDeleted, name), nil)
+		}
+
+		keyboard, ok := h.presetsKeyboard(chatID)
+		if !ok {
+			_ = h.bot.EditMessageText(chatID, callback.Message.MessageID, locale.PresetsEmpty)
+			return
+		}
+		_ = h.bot.EditMessageTextAndMarkup(chatID, callback.Message.MessageID, locale.PresetsTitle, keyboard)
+	}
+}
+
+// presetsKeyboard creates the preset list of a user: own presets with
+// apply and delete buttons, then admin presets that can only be applied.
+// It returns false if there are no presets at all.
+func (h *Handler) presetsKeyboard(chatID int64) (tgbotapi.InlineKeyboardMarkup, bool) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, name := range h.presetSvc.UserPresets(chatID) {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ "+name, callbackPresetApply+name),
+			tgbotapi.NewInlineKeyboardButtonData("🗑", callbackPresetDelete+name),
+		))
+	}
+	for _, name := range h.presetSvc.AdminPresets() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⭐ "+name, callbackPresetApply+name),
+		))
+	}
+
+	if len(rows) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...), true
+}
+
+// presetFromSettings returns the effective settings of a user as a preset,
+// so the preset converts the same way after the global defaults change
+func (h *Handler) presetFromSettings(settings domain.UserSettings) domain.UserSettings {
+	gif := h.effectiveGIF(settings)
+	autoCrop := gif.AutoCrop
+	settings.AutoCrop = &autoCrop
+	settings.Reframe = effectiveReframe(gif)
+	settings.FPS, settings.Width = gif.FPS, gif.Width
+	settings.Quality = gif.Quality
+	settings.Format = settingsFormat(settings)
+	settings.Customize = false
+	return settings
+}
@@ -0,0 +1,250 @@
+package telegram
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+	"gifmaker-bot/internal/infrastructure/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updateContext is an update passing through the middleware chain
+type updateContext struct {
+	update tgbotapi.Update
+	chatID int64          // 0 for updates without a chat
+	userID int64          // 0 for updates without a sender
+	locale *domain.Locale // set by the locale middleware
+}
+
+// updateHandler handles an update
+type updateHandler func(ctx *updateContext)
+
+// middleware wraps an update handler, running code around it or stopping
+// the update before it gets there
+type middleware func(next updateHandler) updateHandler
+
+// Handlers of the routed parts of an update
+type (
+	commandHandler  func(message *tgbotapi.Message, args []string, locale *domain.Locale)
+	callbackHandler func(callback *tgbotapi.CallbackQuery, locale *domain.Locale)
+	messageHandler  func(message *tgbotapi.Message, locale *domain.Locale)
+)
+
+// command is a slash command, optionally bound to a main keyboard button
+type command struct {
+	name    string   // without the slash, as registered in the command menu
+	aliases []string // other names that aren't shown in the menu
+
+	// label returns the main keyboard button of the command in a locale,
+	// it is nil for commands without a button
+	label func(locale *domain.Locale) string
+	// oldLabels are buttons of keyboards sent before the labels were localized
+	oldLabels []string
+	// description returns the command menu entry in a locale
+	description func(locale *domain.Locale) string
+
+	// args is how many space-separated arguments the command takes, a
+	// different number gets the usage message. Commands without arguments
+	// ignore any text after them.
+	args  int
+	usage func(locale *domain.Locale) string
+
+	handle commandHandler
+}
+
+// callbackRoute handles the callbacks whose data starts with a prefix
+type callbackRoute struct {
+	prefix string
+	handle callbackHandler
+}
+
+// messageRoute handles the messages match accepts
+type messageRoute struct {
+	match  func(message *tgbotapi.Message) bool
+	handle messageHandler
+}
+
+// router dispatches updates to the registered handlers through a chain of
+// middleware. Messages go to a command by their slash command or by the
+// main keyboard button label of any locale, so buttons sent before a
+// language change keep working, and then to the first matching message
+// route. Callbacks go to the exact data route or the longest matching prefix.
+type router struct {
+	bot        *telegram.Bot
+	username   string // of the bot, commands for other bots are ignored
+	locales    []*domain.Locale
+	middleware []middleware
+
+	commands  []*command // in the order of the command menu
+	byName    map[string]*command
+	byLabel   map[string]*command
+	callbacks map[string]callbackHandler
+	prefixes  []callbackRoute // longest first
+	messages  []messageRoute
+}
+
+// newRouter creates a router for the button labels of the locales
+func newRouter(bot *telegram.Bot, locales []*domain.Locale) *router {
+	return &router{
+		bot:       bot,
+		username:  bot.GetSelf().UserName,
+		locales:   locales,
+		byName:    make(map[string]*command),
+		byLabel:   make(map[string]*command),
+		callbacks: make(map[string]callbackHandler),
+	}
+}
+
+// Use adds middleware to the chain, the first one added runs first
+func (r *router) Use(mw ...middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Command registers a command with its aliases and button labels
+func (r *router) Command(cmd *command) {
+	r.commands = append(r.commands, cmd)
+	r.byName[cmd.name] = cmd
+	for _, alias := range cmd.aliases {
+		r.byName[alias] = cmd
+	}
+
+	for _, label := range cmd.oldLabels {
+		r.byLabel[label] = cmd
+	}
+	if cmd.label == nil {
+		return
+	}
+	for _, locale := range r.locales {
+		label := cmd.label(locale)
+		if other, ok := r.byLabel[label]; ok && other != cmd {
+			log.Printf("Locale %s: button %q of /%s is already used by /%s", locale.Code, label, cmd.name, other.name)
+			continue
+		}
+		r.byLabel[label] = cmd
+	}
+}
+
+// Callback registers the handler of callbacks with exactly this data
+func (r *router) Callback(data string, handle callbackHandler) {
+	r.callbacks[data] = handle
+}
+
+// CallbackPrefix registers the handler of callbacks whose data starts with prefix
+func (r *router) CallbackPrefix(prefix string, handle callbackHandler) {
+	r.prefixes = append(r.prefixes, callbackRoute{prefix: prefix, handle: handle})
+	slices.SortStableFunc(r.prefixes, func(a, b callbackRoute) int {
+		return len(b.prefix) - len(a.prefix)
+	})
+}
+
+// Message registers the handler of messages that match accepts and no
+// command or earlier message route took
+func (r *router) Message(match func(message *tgbotapi.Message) bool, handle messageHandler) {
+	r.messages = append(r.messages, messageRoute{match: match, handle: handle})
+}
+
+// Commands returns the registered commands in the order of the command menu
+func (r *router) Commands() []*command {
+	return r.commands
+}
+
+// Route returns the command of a message, if it has one
+func (r *router) Route(message *tgbotapi.Message) (*command, bool) {
+	if message.IsCommand() {
+		if r.ForOtherBot(message) {
+			return nil, false
+		}
+		cmd, ok := r.byName[message.Command()]
+		return cmd, ok
+	}
+	if message.Text == "" {
+		return nil, false
+	}
+	cmd, ok := r.byLabel[message.Text]
+	return cmd, ok
+}
+
+// ForOtherBot reports whether a message is a command addressed to another
+// bot, as "/settings@OtherBot" in groups
+func (r *router) ForOtherBot(message *tgbotapi.Message) bool {
+	_, username, addressed := strings.Cut(message.CommandWithAt(), "@")
+	return addressed && !strings.EqualFold(username, r.username)
+}
+
+// Handler returns the handler of updates wrapped in the middleware
+func (r *router) Handler() func(update tgbotapi.Update) {
+	handle := r.dispatch
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handle = r.middleware[i](handle)
+	}
+
+	return func(update tgbotapi.Update) {
+		ctx := &updateContext{update: update}
+		if chat := update.FromChat(); chat != nil {
+			ctx.chatID = chat.ID
+		}
+		if from := update.SentFrom(); from != nil {
+			ctx.userID = from.ID
+		}
+		handle(ctx)
+	}
+}
+
+// dispatch calls the handler registered for an update
+func (r *router) dispatch(ctx *updateContext) {
+	switch update := ctx.update; {
+	case update.CallbackQuery != nil:
+		r.dispatchCallback(update.CallbackQuery, ctx.locale)
+	case update.Message != nil:
+		r.dispatchMessage(update.Message, ctx.locale)
+	}
+}
+
+func (r *router) dispatchCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	// Callbacks of inline mode messages have no chat to answer in
+	if callback.Message == nil {
+		return
+	}
+
+	if handle, ok := r.callbacks[callback.Data]; ok {
+		handle(callback, locale)
+		return
+	}
+	for _, route := range r.prefixes {
+		if strings.HasPrefix(callback.Data, route.prefix) {
+			route.handle(callback, locale)
+			return
+		}
+	}
+}
+
+func (r *router) dispatchMessage(message *tgbotapi.Message, locale *domain.Locale) {
+	if cmd, ok := r.Route(message); ok {
+		var args []string
+		if message.IsCommand() {
+			args = strings.Fields(message.CommandArguments())
+		}
+		if cmd.args > 0 && len(args) != cmd.args {
+			if cmd.usage != nil {
+				_, _ = r.bot.SendMessage(message.Chat.ID, cmd.usage(locale), nil)
+			}
+			return
+		}
+		cmd.handle(message, args, locale)
+		return
+	}
+	// Unknown commands are ignored
+	if message.IsCommand() {
+		return
+	}
+
+	for _, route := range r.messages {
+		if route.match(message) {
+			route.handle(message, locale)
+			return
+		}
+	}
+}
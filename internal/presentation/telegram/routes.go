@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"fmt"
+
+	"gifmaker-bot/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackLanguagePrefix is followed by the code of the picked language
+const callbackLanguagePrefix = "lang_"
+
+// routes registers the middleware and the handlers of every update the bot
+// handles. New commands, buttons and message kinds are added here.
+func (h *Handler) routes() *router {
+	r := newRouter(h.bot, h.localeSvc.Languages())
+	r.Use(recoverPanics, logUpdates, h.checkAccess, h.limitRate, h.injectLocale, h.interruptTextWizard)
+
+	// Commands in the order of the command menu
+	r.Command(&command{
+		name:        "start",
+		description: func(l *domain.Locale) string { return l.CommandStart },
+		handle:      h.handleStartCommand,
+	})
+	r.Command(&command{
+		name:        "help",
+		label:       func(l *domain.Locale) string { return l.HelpMessage },
+		oldLabels:   []string{"📖 Справка / Help"},
+		description: func(l *domain.Locale) string { return l.CommandHelp },
+		handle:      h.handleHelpCommand,
+	})
+	r.Command(&command{
+		name:        "language",
+		aliases:     []string{"lang"},
+		label:       func(l *domain.Locale) string { return l.ButtonLanguage },
+		oldLabels:   []string{"🌐 Язык / Language"},
+		description: func(l *domain.Locale) string { return l.CommandLanguage },
+		handle:      h.handleLanguageCommand,
+	})
+	r.Command(&command{
+		name:        "settings",
+		description: func(l *domain.Locale) string { return l.CommandSettings },
+		handle:      h.handleSettingsCommand,
+	})
+	r.Command(&command{
+		name:        "presets",
+		description: func(l *domain.Locale) string { return l.CommandPresets },
+		handle:      h.handlePresetsCommand,
+	})
+	r.Command(&command{
+		name:        "savepreset",
+		description: func(l *domain.Locale) string { return l.CommandSavePreset },
+		args:        1,
+		usage:       func(l *domain.Locale) string { return l.PresetUsage },
+		handle:      h.handleSavePresetCommand,
+	})
+	r.Command(&command{
+		name:        "text",
+		description: func(l *domain.Locale) string { return l.CommandText },
+		handle:      h.handleTextCommand,
+	})
+	r.Command(&command{
+		name:        "notext",
+		description: func(l *domain.Locale) string { return l.CommandNoText },
+		handle:      h.handleNoTextCommand,
+	})
+	r.Command(&command{
+		name:        "videonote",
+		description: func(l *domain.Locale) string { return l.CommandVideoNote },
+		handle:      h.handleVideoNoteCommand,
+	})
+
+	// Inline keyboard buttons
+	r.CallbackPrefix(callbackLanguagePrefix, h.handleLanguageCallback)
+	r.CallbackPrefix(callbackSettingsPrefix, h.handleSettingsCallback)
+	r.CallbackPrefix(callbackPresetPrefix, h.handlePresetCallback)
+	r.CallbackPrefix(callbackCustomizePrefix, h.handleCustomizeCallback)
+	r.CallbackPrefix(domain.CallbackHighlightPrefix, h.handleRunnerUpCallback)
+	for _, data := range []string{domain.CallbackTimelapse, domain.CallbackSplit, domain.CallbackHighlight} {
+		r.Callback(data, h.handleSourceCallback)
+	}
+	for _, data := range []string{domain.CallbackRenderSmaller, domain.CallbackRenderBetter, domain.CallbackRenderFaster,
+		domain.CallbackRenderMP4, domain.CallbackRenderSticker} {
+		r.Callback(data, h.handleRerenderCallback)
+	}
+
+	// Messages that aren't commands, complete albums come back as a copy
+	// of their first photo message
+	r.Message(h.albums.IsAlbum, h.handleAlbumMessage)
+	r.Message(func(m *tgbotapi.Message) bool { return m.Text != "" }, h.handleTextMessage)
+	r.Message(func(m *tgbotapi.Message) bool { return m.Video != nil }, h.handleVideoMessage)
+	r.Message(func(m *tgbotapi.Message) bool { return m.VideoNote != nil }, h.handleVideoNoteMessage)
+	r.Message(func(m *tgbotapi.Message) bool { return len(m.Photo) > 0 }, h.handlePhotoMessage)
+	r.Message(func(m *tgbotapi.Message) bool { return m.Document != nil }, h.handleDocumentMessage)
+
+	return r
+}
+
+// RegisterCommands sets the command menu of every loaded language. Users
+// whose Telegram language has no locale see the menu of the default one.
+func (h *Handler) RegisterCommands() error {
+	if err := h.bot.SetCommands("", menuCommands(h.router.Commands(), h.localeSvc.DefaultLocale())); err != nil {
+		return fmt.Errorf("failed to set default commands: %w", err)
+	}
+
+	for _, locale := range h.localeSvc.Languages() {
+		if err := h.bot.SetCommands(locale.Code, menuCommands(h.router.Commands(), locale)); err != nil {
+			return fmt.Errorf("failed to set %s commands: %w", locale.Code, err)
+		}
+	}
+	return nil
+}
+
+// menuCommands returns the command menu of a locale
+func menuCommands(commands []*command, locale *domain.Locale) []tgbotapi.BotCommand {
+	menu := make([]tgbotapi.BotCommand, 0, len(commands))
+	for _, cmd := range commands {
+		menu = append(menu, tgbotapi.BotCommand{Command: cmd.name, Description: cmd.description(locale)})
+	}
+	return menu
+}
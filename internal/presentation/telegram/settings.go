@@ -0,0 +1,186 @@
+package telegram
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleSettingsCommand shows the conversion settings of a chat
+func (h *Handler) handleSettingsCommand(message *tgbotapi.Message, _ []string, locale *domain.Locale) {
+	chatID := message.Chat.ID
+	settings := h.settingsSvc.Get(chatID)
+	gif := h.effectiveGIF(settings)
+	_, _ = h.bot.SendMessage(chatID, formatSettings(settings, gif, locale),
+		CreateSettingsKeyboard(gif, settingsFormat(settings), settings.Customize, locale))
+}
+
+// handleSettingsCallback applies a settings keyboard button press
+func (h *Handler) handleSettingsCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	switch data := callback.Data; {
+	case data == callbackSettingsText:
+		h.textWizard.Start(chatID)
+		_, _ = h.bot.SendMessage(chatID, locale.TextAskTop, nil)
+		return
+
+	case data == callbackSettingsReverse:
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Reverse = !settings.Reverse
+		})
+
+	case data == callbackSettingsBoomerang:
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Boomerang = !settings.Boomerang
+		})
+
+	case data == callbackSettingsLoop:
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Loop = !settings.Loop
+		})
+
+	case data == callbackSettingsCustomize:
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Customize = !settings.Customize
+		})
+
+	case data == callbackSettingsAutoCrop:
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			enabled := !settings.AutoCropEnabled(h.config.GIF.AutoCrop)
+			settings.AutoCrop = &enabled
+		})
+
+	case strings.HasPrefix(data, callbackSettingsReframe):
+		mode := domain.ReframeMode(strings.TrimPrefix(data, callbackSettingsReframe))
+		if !mode.IsValid() {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Reframe = mode
+		})
+
+	case strings.HasPrefix(data, callbackSettingsQuality):
+		quality := strings.TrimPrefix(data, callbackSettingsQuality)
+		if !slices.Contains(domain.QualityLevels, quality) {
+			return
+		}
+		// A new quality brings its own palette settings
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Quality = quality
+			settings.Dither = ""
+			settings.PaletteMode = ""
+		})
+
+	case strings.HasPrefix(data, callbackSettingsDither):
+		mode := domain.DitherMode(strings.TrimPrefix(data, callbackSettingsDither))
+		if !mode.IsValid() {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Dither = mode
+		})
+
+	case strings.HasPrefix(data, callbackSettingsPalette):
+		mode := domain.PaletteMode(strings.TrimPrefix(data, callbackSettingsPalette))
+		if !mode.IsValid() {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.PaletteMode = mode
+		})
+
+	case strings.HasPrefix(data, callbackSettingsFPS):
+		fps, err := strconv.Atoi(strings.TrimPrefix(data, callbackSettingsFPS))
+		if err != nil || !slices.Contains(customizeFPS, fps) {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.FPS = fps
+		})
+
+	case strings.HasPrefix(data, callbackSettingsWidth):
+		width, err := strconv.Atoi(strings.TrimPrefix(data, callbackSettingsWidth))
+		if err != nil || !slices.Contains(customizeWidths, width) {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Width = width
+		})
+
+	case strings.HasPrefix(data, callbackSettingsFormat):
+		format := domain.OutputFormat(strings.TrimPrefix(data, callbackSettingsFormat))
+		if !slices.Contains(domain.AnimationFormats, format) {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Format = format
+		})
+
+	case strings.HasPrefix(data, callbackSettingsSpeed):
+		speed, err := strconv.ParseFloat(strings.TrimPrefix(data, callbackSettingsSpeed), 64)
+		if err != nil || !domain.IsValidSpeed(speed) {
+			return
+		}
+		h.settingsSvc.Update(chatID, func(settings *domain.UserSettings) {
+			settings.Speed = speed
+		})
+
+	default:
+		return
+	}
+
+	settings := h.settingsSvc.Get(chatID)
+	gif := h.effectiveGIF(settings)
+	_ = h.bot.EditMessageTextAndMarkup(chatID, callback.Message.MessageID,
+		formatSettings(settings, gif, locale), CreateSettingsKeyboard(gif, settingsFormat(settings), settings.Customize, locale))
+}
+
+// effectiveGIF returns the global GIF settings with user settings applied
+func (h *Handler) effectiveGIF(settings domain.UserSettings) domain.GIFConfig {
+	gif := h.config.GIF
+	settings.Apply(&gif)
+	return gif
+}
+
+// settingsFormat returns the output format of animations for a user
+func settingsFormat(settings domain.UserSettings) domain.OutputFormat {
+	if settings.Format == "" {
+		return domain.FormatGIF
+	}
+	return settings.Format
+}
+
+// formatSettings describes user settings for the settings message
+func formatSettings(settings domain.UserSettings, gif domain.GIFConfig, locale *domain.Locale) string {
+	text := locale.SettingNone
+	if settings.TopText != "" || settings.BottomText != "" {
+		text = fmt.Sprintf("«%s» / «%s»", settings.TopText, settings.BottomText)
+	}
+
+	lines := []string{
+		locale.SettingsTitle,
+		"",
+		fmt.Sprintf("%s: %s", locale.SettingsSpeed, formatSpeed(gif.PlaybackSpeed())),
+		fmt.Sprintf("%s: %s", locale.SettingsReverse, formatToggle(gif.Reverse, locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsBoomerang, formatToggle(gif.Boomerang, locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsLoop, formatToggle(gif.PerfectLoop, locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsAutoCrop, formatToggle(gif.AutoCrop, locale)),
+		fmt.Sprintf("%s: %d", locale.SettingsFPS, gif.FPS),
+		fmt.Sprintf("%s: %s", locale.SettingsWidth, widthLabel(gif.Width, locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsFormat, formatLabel(settingsFormat(settings))),
+		fmt.Sprintf("%s: %s", locale.SettingsReframe, reframeLabel(effectiveReframe(gif), locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsQuality, qualityLabel(gif.Quality, locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsDither, ditherLabel(gif.Dither)),
+		fmt.Sprintf("%s: %s", locale.SettingsPalette, paletteLabel(effectivePaletteMode(gif), locale)),
+		fmt.Sprintf("%s: %s", locale.SettingsText, text),
+		fmt.Sprintf("%s: %s", locale.SettingsCustomize, formatToggle(settings.Customize, locale)),
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,172 @@
+package telegram
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"gifmaker-bot/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Re-render adjustments
+const (
+	smallerWidthRatio = 2.0 / 3
+	minRerenderWidth  = 160
+	// defaultRerenderWidth is assumed when a result kept the video width
+	defaultRerenderWidth = 480
+	betterFPSRatio       = 1.5
+	fasterSpeedRatio     = 1.5
+)
+
+// handleSourceCallback converts a video that was too long again, as a
+// timelapse, as a series of GIFs or as its best part. The best part is
+// converted by default, so its button is only on messages sent before that.
+func (h *Handler) handleSourceCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	source, ok := h.sources.Get(chatID, callback.Message.MessageID)
+	if !ok {
+		_, _ = h.bot.SendMessage(chatID, locale.SourceExpired, nil)
+		return
+	}
+
+	// The buttons convert the video once, the other buttons of a result
+	// stay
+	_ = h.bot.EditInlineKeyboard(chatID, callback.Message.MessageID, withoutSourceButtons(callback.Message.ReplyMarkup))
+
+	task := sourceTask(source, chatID)
+	switch callback.Data {
+	case domain.CallbackTimelapse:
+		task.Options.Highlight = false
+		task.Options.Timelapse = true
+	case domain.CallbackSplit:
+		task.Options.Highlight = false
+		task.Options.Split = true
+	case domain.CallbackHighlight:
+		task.Options.Highlight = true
+	}
+	h.processVideoFile(task, locale)
+}
+
+// withoutSourceButtons returns a keyboard without the timelapse, split and
+// best part buttons, dropping rows left empty
+func withoutSourceButtons(markup *tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if markup == nil {
+		return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	}
+
+	sourceData := []string{domain.CallbackTimelapse, domain.CallbackSplit, domain.CallbackHighlight}
+	for _, row := range markup.InlineKeyboard {
+		row = slices.DeleteFunc(slices.Clone(row), func(button tgbotapi.InlineKeyboardButton) bool {
+			return button.CallbackData != nil && slices.Contains(sourceData, *button.CallbackData)
+		})
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// handleRunnerUpCallback converts a runner-up highlight offered under a GIF
+func (h *Handler) handleRunnerUpCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	index, err := strconv.Atoi(strings.TrimPrefix(callback.Data, domain.CallbackHighlightPrefix))
+	if err != nil {
+		return
+	}
+
+	source, ok := h.sources.Get(chatID, callback.Message.MessageID)
+	if !ok || index <= 0 || index >= len(source.Highlights) {
+		_, _ = h.bot.SendMessage(chatID, locale.SourceExpired, nil)
+		return
+	}
+
+	// The runner-up is converted like a trimmed video
+	task := sourceTask(source, chatID)
+	task.Options.Highlight = false
+	task.Options.Trim = source.Highlights[index]
+	h.processVideoFile(task, locale)
+}
+
+// handleRerenderCallback converts the video of a result again with
+// adjusted settings or in another format
+func (h *Handler) handleRerenderCallback(callback *tgbotapi.CallbackQuery, locale *domain.Locale) {
+	chatID := callback.Message.Chat.ID
+	_ = h.bot.AnswerCallback(callback.ID)
+
+	source, ok := h.sources.Get(chatID, callback.Message.MessageID)
+	if !ok {
+		_, _ = h.bot.SendMessage(chatID, locale.SourceExpired, nil)
+		return
+	}
+
+	task := sourceTask(source, chatID)
+	options := &task.Options
+
+	// A highlight is converted again from the same part of the video
+	if options.Highlight && len(source.Highlights) > 0 {
+		options.Highlight = false
+		options.Trim = source.Highlights[0]
+	}
+
+	switch callback.Data {
+	case domain.CallbackRenderSmaller:
+		width := source.Width
+		if width <= 0 {
+			width = defaultRerenderWidth
+		}
+		options.Width = max(int(float64(width)*smallerWidthRatio)&^1, minRerenderWidth)
+		options.Quality = stepQuality(source.Quality, -1)
+
+	case domain.CallbackRenderBetter:
+		options.Quality = stepQuality(source.Quality, 1)
+		// Never lower the frame rate of a GIF that is already smooth
+		options.FPS = max(source.FPS, min(int(float64(source.FPS)*betterFPSRatio), domain.MaxFPS))
+
+	case domain.CallbackRenderFaster:
+		speed := source.Speed * fasterSpeedRatio
+		// Timelapses may already be faster than the speed effect allows
+		if source.Speed <= domain.MaxSpeed {
+			speed = min(speed, domain.MaxSpeed)
+		}
+		options.Speed = speed
+
+	case domain.CallbackRenderMP4:
+		task.Format = domain.FormatMP4
+
+	case domain.CallbackRenderSticker:
+		task.Format = domain.FormatSticker
+	}
+
+	h.processVideoFile(task, locale)
+}
+
+// sourceTask creates a task that converts a stored video source again
+func sourceTask(source domain.VideoSource, chatID int64) *domain.ProcessingTask {
+	return &domain.ProcessingTask{
+		MessageID:    source.MessageID,
+		ChatID:       chatID,
+		VideoFileID:  source.FileID,
+		PhotoFileIDs: source.PhotoFileIDs,
+		IsVideoNote:  source.IsVideoNote,
+		Format:       source.Format,
+		Options:      source.Options,
+	}
+}
+
+// stepQuality returns the quality level next to the given one in the
+// direction of step, staying at the lowest or highest level
+func stepQuality(quality string, step int) string {
+	index := slices.Index(domain.QualityLevels, quality)
+	if index < 0 {
+		index = slices.Index(domain.QualityLevels, domain.QualityMedium)
+	}
+	index = min(max(index+step, 0), len(domain.QualityLevels)-1)
+	return domain.QualityLevels[index]
+}
@@ -0,0 +1,65 @@
+package telegram
+
+import "sync"
+
+// textWizardStep is the caption the wizard is waiting for
+type textWizardStep int
+
+const (
+	textStepTop textWizardStep = iota + 1
+	textStepBottom
+)
+
+// textWizardState is the progress of a chat through the caption wizard
+type textWizardState struct {
+	step textWizardStep
+	top  string
+}
+
+// textWizard tracks chats that are entering meme captions step by step
+type textWizard struct {
+	mu     sync.Mutex
+	states map[int64]textWizardState // chatID -> state
+}
+
+// newTextWizard creates a new caption wizard
+func newTextWizard() *textWizard {
+	return &textWizard{
+		states: make(map[int64]textWizardState),
+	}
+}
+
+// Start starts the wizard for a chat
+func (w *textWizard) Start(chatID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.states[chatID] = textWizardState{step: textStepTop}
+}
+
+// Cancel stops the wizard for a chat
+func (w *textWizard) Cancel(chatID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.states, chatID)
+}
+
+// Advance records the text for the current step and returns the state it
+// was recorded for. The wizard ends after the bottom caption.
+func (w *textWizard) Advance(chatID int64, text string) (textWizardState, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state, ok := w.states[chatID]
+	if !ok {
+		return state, false
+	}
+
+	switch state.step {
+	case textStepTop:
+		w.states[chatID] = textWizardState{step: textStepBottom, top: text}
+	case textStepBottom:
+		delete(w.states, chatID)
+	}
+
+	return state, true
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"gifmaker-bot/internal/application/service"
@@ -12,11 +13,20 @@ import (
 	"gifmaker-bot/internal/domain"
 	"gifmaker-bot/internal/infrastructure/config"
 	"gifmaker-bot/internal/infrastructure/ffmpeg"
+	"gifmaker-bot/internal/infrastructure/locales"
 	"gifmaker-bot/internal/infrastructure/storage"
 	"gifmaker-bot/internal/infrastructure/telegram"
 	telegramhandler "gifmaker-bot/internal/presentation/telegram"
 )
 
+// Defaults for settings missing in the config
+const (
+	defaultDataDir       = "data"
+	defaultLanguage      = "ru"
+	defaultUpdateWorkers = 8
+	defaultUpdateBacklog = 1000
+)
+
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig("config.yaml")
@@ -44,12 +54,45 @@ func main() {
 	converter := ffmpeg.NewConverter()
 	fileStore := storage.NewFileStorage()
 
+	dataDir := cfg.Storage.DataDir
+	if dataDir == "" {
+		dataDir = defaultDataDir
+	}
+
+	defaultLang := cfg.Bot.DefaultLanguage
+	if defaultLang == "" {
+		defaultLang = defaultLanguage
+	}
+	localeBundles, err := locales.Load(cfg.Bot.LocalesDir, defaultLang)
+	if err != nil {
+		log.Fatalf("Failed to load locales: %v", err)
+	}
+
 	// Initialize domain
 	userLang := domain.NewUserLanguage()
+	userSettings := domain.NewUserSettingsStore()
+	presets := domain.NewPresetStore()
+	videoSources := domain.NewVideoSourceStore()
+	quota := domain.NewUsageQuota(cfg.Processing.DailyQuota)
+	rateLimiter := domain.NewRateLimiter(cfg.Access.RateLimit)
 	queue := domain.NewProcessingQueue(cfg.Processing.MaxConcurrent)
 
 	// Initialize services
-	localeSvc := service.NewLocaleService(userLang)
+	localeSvc, err := service.NewLocaleService(userLang, localeBundles, defaultLang, service.NewLocaleParams(cfg), storage.NewJSONFile(filepath.Join(dataDir, "languages.json")))
+	if err != nil {
+		log.Fatalf("Failed to render locales: %v", err)
+	}
+	if err := localeSvc.Load(); err != nil {
+		log.Fatalf("Failed to load user languages: %v", err)
+	}
+	settingsSvc := service.NewSettingsService(userSettings, storage.NewJSONFile(filepath.Join(dataDir, "settings.json")))
+	if err := settingsSvc.Load(); err != nil {
+		log.Fatalf("Failed to load user settings: %v", err)
+	}
+	presetSvc := service.NewPresetService(presets, cfg.Presets, storage.NewJSONFile(filepath.Join(dataDir, "presets.json")))
+	if err := presetSvc.Load(); err != nil {
+		log.Fatalf("Failed to load presets: %v", err)
+	}
 
 	// Initialize use cases
 	videoProcessor := usecase.NewVideoProcessor(
@@ -58,6 +101,9 @@ func main() {
 		fileStore,
 		cfg,
 		localeSvc,
+		settingsSvc,
+		videoSources,
+		quota,
 	)
 
 	queueMgr := usecase.NewQueueManager(
@@ -76,8 +122,15 @@ func main() {
 		bot,
 		queueMgr,
 		localeSvc,
+		settingsSvc,
+		presetSvc,
+		videoSources,
+		rateLimiter,
 		cfg,
 	)
+	if err := handler.RegisterCommands(); err != nil {
+		log.Printf("Failed to register bot commands: %v", err)
+	}
 
 	// Setup update channel
 	updates := bot.GetUpdatesChan(60)
@@ -97,14 +150,16 @@ func main() {
 	}()
 
 	// Process updates
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Bot stopped")
-			return
-		case update := <-updates:
-			handler.HandleUpdate(update)
-		}
+	workers := cfg.Updates.Workers
+	if workers <= 0 {
+		workers = defaultUpdateWorkers
 	}
+	backlog := cfg.Updates.Backlog
+	if backlog <= 0 {
+		backlog = defaultUpdateBacklog
+	}
+	dispatcher := telegramhandler.NewDispatcher(handler.HandleUpdate, workers, backlog)
+	handler.UseDispatcher(dispatcher)
+	dispatcher.Run(ctx, updates)
+	log.Println("Bot stopped")
 }
-